@@ -0,0 +1,76 @@
+// Package commands holds the PulsePoint specific subcommands of the PocketBase CLI.
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"pulsepoint/internal/tasks"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/cobra"
+)
+
+// NewSyncCommand creates the "sync" command, running a sync task from the command line:
+//
+//	pulsepoint sync commodities
+//	pulsepoint sync starSystems --dry-run
+//	pulsepoint sync starSystems --stage space_stations
+//
+// The status of the run is printed as JSON, including the diff for a dry run.
+// The command fails when the task does.
+func NewSyncCommand(app core.App, runner *tasks.Runner) *cobra.Command {
+	var dryRun bool
+	var stage string
+
+	command := &cobra.Command{
+		Use:          "sync <task>",
+		Short:        "Runs a UEX sync task (" + strings.Join(tasks.Names(), ", ") + ")",
+		Args:         cobra.ExactArgs(1),
+		ValidArgs:    tasks.Names(),
+		SilenceUsage: true,
+		RunE: func(command *cobra.Command, args []string) error {
+			l := app.Logger().WithGroup("commands")
+
+			name := args[0]
+			fn, ok := tasks.Lookup(name)
+			if !ok {
+				return fmt.Errorf("unknown task %q, expected one of: %s", name, strings.Join(tasks.Names(), ", "))
+			}
+
+			if stage != "" {
+				if fn, ok = tasks.LookupStage(name, stage); !ok {
+					return fmt.Errorf("task %q has no stage %q", name, stage)
+				}
+			}
+
+			l.Info("Running sync task from the command line", "task", name, "stage", stage, "dry_run", dryRun)
+
+			var run *tasks.SyncRun
+			var err error
+			if dryRun {
+				run, err = runner.DryRun(name, tasks.TriggerManual, fn)
+			} else {
+				run, err = runner.Run(name, tasks.TriggerManual, fn)
+			}
+			if err != nil {
+				return err
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(run.Snapshot()); err != nil {
+				return err
+			}
+
+			return run.Err()
+		},
+	}
+
+	command.Flags().BoolVar(&dryRun, "dry-run", false, "report what would be written without committing anything")
+	command.Flags().StringVar(&stage, "stage", "", "run a single stage of a staged task, e.g. planets")
+
+	return command
+}
@@ -0,0 +1,76 @@
+// Package errs defines the error kinds shared by the PulsePoint tasks, hooks and routes.
+//
+// Functions wrap the underlying error with one of the sentinel errors below
+// (e.g. fmt.Errorf("fetching commodities: %w: %w", errs.ErrUpstreamUnavailable, err)),
+// so callers can classify a failure with errors.Is and still log the full cause.
+package errs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrUpstreamUnavailable means UEX could not be reached or answered with a temporary failure.
+	ErrUpstreamUnavailable = errors.New("upstream API is unavailable")
+
+	// ErrUpstreamResponse means UEX answered, but with something we can't use (4xx, invalid payload, ...).
+	ErrUpstreamResponse = errors.New("unexpected upstream API response")
+
+	// ErrCollectionMissing means a collection the code depends on doesn't exist.
+	ErrCollectionMissing = errors.New("collection is missing")
+
+	// ErrMisconfigured means a required configuration value is missing or invalid.
+	ErrMisconfigured = errors.New("missing or invalid configuration")
+)
+
+// Error codes reported to API clients, see Code.
+const (
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeUpstreamResponse    = "upstream_response"
+	CodeCollectionMissing   = "collection_missing"
+	CodeMisconfigured       = "misconfigured"
+	CodeCancelled           = "cancelled"
+	CodeTimeout             = "timeout"
+	CodeInternal            = "internal"
+)
+
+// Code returns a stable, machine readable code describing err.
+// It returns an empty string for a nil error.
+func Code(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrUpstreamUnavailable):
+		return CodeUpstreamUnavailable
+	case errors.Is(err, ErrUpstreamResponse):
+		return CodeUpstreamResponse
+	case errors.Is(err, ErrCollectionMissing):
+		return CodeCollectionMissing
+	case errors.Is(err, ErrMisconfigured):
+		return CodeMisconfigured
+	case errors.Is(err, context.DeadlineExceeded):
+		return CodeTimeout
+	case errors.Is(err, context.Canceled):
+		return CodeCancelled
+	default:
+		return CodeInternal
+	}
+}
+
+// HTTPStatus maps err to the HTTP status a route should answer with.
+func HTTPStatus(err error) int {
+	switch Code(err) {
+	case "":
+		return http.StatusOK
+	case CodeUpstreamUnavailable, CodeCancelled:
+		return http.StatusServiceUnavailable
+	case CodeUpstreamResponse:
+		return http.StatusBadGateway
+	case CodeTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
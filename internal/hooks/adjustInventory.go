@@ -0,0 +1,160 @@
+package hooks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"pulsepoint/internal/errs"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// DefaultChangeReason is the reason of the adjustments made without one.
+const DefaultChangeReason = "adjusted"
+
+// Adjustment is a change of the stock of a commodity at an outpost.
+type Adjustment struct {
+	Commodity string  `json:"commodity"`
+	Delta     float64 `json:"delta"`
+}
+
+// AdjustInventory applies the adjustments to the stock of the outpost, in one transaction: for every
+// adjustment the change is appended to the ledger first (see RecordCommodityChange), then the amount
+// of the outpost commodity is updated. The outpost commodity is created when the outpost tracks the
+// commodity but has no row for it yet. Either every adjustment is applied, or none is.
+//
+// Parameters:
+//
+//	app (core.App): The application.
+//	outpost (*core.Record): The outpost whose stock changes.
+//	adjustments ([]Adjustment): The changes, at most one per commodity.
+//	details (ChangeDetails): Who makes the changes, why and what they relate to, recorded with every change.
+//
+// Returns:
+//
+//	[]*core.Record: The updated outpost commodities, in the order of the adjustments.
+//	error: validation.Errors for invalid adjustments (unknown or untracked commodity, stock going
+//	negative, ...), so the caller can answer 400 with the field errors. Otherwise the wrapped error of the failed step.
+func AdjustInventory(app core.App, outpost *core.Record, adjustments []Adjustment, details ChangeDetails) ([]*core.Record, error) {
+	l := app.Logger().WithGroup("adjustInventory")
+
+	if details.Reason == "" {
+		details.Reason = DefaultChangeReason
+	}
+	if err := validateChangeDetails(app, details); err != nil {
+		return nil, err
+	}
+	if err := validateAdjustments(adjustments); err != nil {
+		return nil, err
+	}
+
+	var updated []*core.Record
+	err := app.RunInTransaction(func(txPb core.App) error {
+		outpostCommodityCollection, err := txPb.FindCollectionByNameOrId("outpost_commodities")
+		if err != nil {
+			l.Error("Error finding outpost_commodities collection", "error", err)
+			return fmt.Errorf("%w: outpost_commodities: %w", errs.ErrCollectionMissing, err)
+		}
+
+		updated = make([]*core.Record, 0, len(adjustments))
+		for i, adjustment := range adjustments {
+			fieldError := func(field string, code string, message string) error {
+				return validation.Errors{"changes": validation.Errors{
+					strconv.Itoa(i): validation.Errors{field: validation.NewError(code, message)},
+				}}
+			}
+
+			commodity, err := txPb.FindRecordById("commodities", adjustment.Commodity)
+			if err != nil {
+				return fieldError("commodity", "validation_invalid_commodity", "Unknown commodity.")
+			}
+
+			outpostCommodity, err := txPb.FindFirstRecordByFilter(
+				"outpost_commodities",
+				"outpost = {:outpost} && commodity = {:commodity} && archived = false",
+				dbx.Params{"outpost": outpost.Id, "commodity": commodity.Id},
+			)
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				// First change of the stock of this commodity, its row is created on demand
+				if !TracksCommodity(outpost, commodity) {
+					return fieldError("commodity", "validation_commodity_not_tracked", "The outpost doesn't track this commodity.")
+				}
+
+				outpostCommodity = core.NewRecord(outpostCommodityCollection)
+				outpostCommodity.Set("organization", outpost.GetString("organization"))
+				outpostCommodity.Set("outpost", outpost.Id)
+				outpostCommodity.Set("commodity", commodity.Id)
+				outpostCommodity.Set("amount", 0)
+				if err := txPb.Save(outpostCommodity); err != nil {
+					l.Error("Failed to save new outpost commodity", "error", err.Error(), "outpost_id", outpost.Id, "commodity_id", commodity.Id)
+					return fmt.Errorf("saving outpost commodity for commodity %s: %w", commodity.Id, err)
+				}
+			case err != nil:
+				return fmt.Errorf("finding outpost commodity for commodity %s: %w", commodity.Id, err)
+			}
+
+			newAmount := outpostCommodity.GetFloat("amount") + adjustment.Delta
+			if newAmount < 0 {
+				return fieldError("delta", "validation_insufficient_stock", fmt.Sprintf("Only %g in stock.", outpostCommodity.GetFloat("amount")))
+			}
+
+			// Ledger first, the amount follows from it
+			if err := RecordCommodityChange(txPb, outpostCommodity, adjustment.Delta, details); err != nil {
+				return err
+			}
+
+			outpostCommodity.Set("amount", newAmount)
+			if err := txPb.Save(outpostCommodity); err != nil {
+				l.Error("Failed to update outpost commodity", "error", err.Error(), "outpost_commodity_id", outpostCommodity.Id)
+				return fmt.Errorf("updating outpost commodity %s: %w", outpostCommodity.Id, err)
+			}
+
+			updated = append(updated, outpostCommodity)
+		}
+
+		return nil
+	})
+	if err != nil {
+		var fieldErrors validation.Errors
+		if errors.As(err, &fieldErrors) {
+			return nil, fieldErrors
+		}
+		return nil, fmt.Errorf("adjusting inventory of outpost %s: %w", outpost.Id, err)
+	}
+
+	l.Info("Adjusted inventory", "outpost_id", outpost.Id, "changes", len(updated), "reason", details.Reason, "actor", details.Actor)
+
+	return updated, nil
+}
+
+// validateAdjustments checks the adjustments before anything is written.
+func validateAdjustments(adjustments []Adjustment) error {
+	if len(adjustments) == 0 {
+		return validation.Errors{"changes": validation.NewError("validation_required", "At least one change is required.")}
+	}
+
+	fieldErrors := validation.Errors{}
+	seen := map[string]bool{}
+	for i, adjustment := range adjustments {
+		switch {
+		case adjustment.Commodity == "":
+			fieldErrors[strconv.Itoa(i)] = validation.Errors{"commodity": validation.NewError("validation_required", "Cannot be blank.")}
+		case seen[adjustment.Commodity]:
+			fieldErrors[strconv.Itoa(i)] = validation.Errors{"commodity": validation.NewError("validation_duplicate_commodity", "Only one change per commodity.")}
+		case adjustment.Delta == 0:
+			fieldErrors[strconv.Itoa(i)] = validation.Errors{"delta": validation.NewError("validation_required", "The change can't be 0.")}
+		}
+		seen[adjustment.Commodity] = true
+	}
+
+	if len(fieldErrors) > 0 {
+		return validation.Errors{"changes": fieldErrors}
+	}
+
+	return nil
+}
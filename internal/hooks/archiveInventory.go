@@ -0,0 +1,165 @@
+package hooks
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/apis"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/viper"
+)
+
+// Policies applied to the inventory when an outpost or a commodity is deleted, see DeletePolicy.
+const (
+	// DeletePolicyBlock refuses the deletion while the inventory holds stock, and archives it otherwise.
+	DeletePolicyBlock = "block"
+
+	// DeletePolicyArchive always archives the inventory, whatever its stock.
+	DeletePolicyArchive = "archive"
+)
+
+// inventoryRelation maps the collections whose deletion archives the inventory to the
+// relation field of outpost_commodities and outpost_commodity_changes pointing to them.
+var inventoryRelation = map[string]string{
+	"outposts":    "outpost",
+	"commodities": "commodity",
+}
+
+// DeletePolicy returns the policy applied to the inventory when a record of the collection is deleted,
+// read from <COLLECTION>_DELETE_POLICY (e.g. OUTPOSTS_DELETE_POLICY). It defaults to DeletePolicyBlock.
+//
+// Parameters:
+//
+//	collection (string): The name of the collection, "outposts" or "commodities".
+//
+// Returns:
+//
+//	string: DeletePolicyBlock or DeletePolicyArchive.
+func DeletePolicy(collection string) string {
+	if strings.EqualFold(viper.GetString(strings.ToUpper(collection)+"_DELETE_POLICY"), DeletePolicyArchive) {
+		return DeletePolicyArchive
+	}
+	return DeletePolicyBlock
+}
+
+// ArchiveInventory is a hook function that runs before an outpost or a commodity is deleted.
+// Its outpost_commodities rows and their outpost_commodity_changes ledger rows are archived,
+// with a snapshot of the outpost and commodity names, so the history outlives the deleted record
+// (PocketBase then only unsets their relation to it). With DeletePolicyBlock the deletion is
+// refused with 409 Conflict while any of the rows still holds stock.
+//
+// Parameters:
+//
+//	e (*core.RecordEvent): The event of the outpost or commodity being deleted.
+//
+// Returns:
+//
+//	error: An *router.ApiError when the deletion is blocked, otherwise the wrapped error of the failed step.
+//	The caller should fail the deletion with it.
+func ArchiveInventory(e *core.RecordEvent) error {
+	collection := e.Record.Collection().Name
+	relation, ok := inventoryRelation[collection]
+	if !ok {
+		return nil
+	}
+
+	l := e.App.Logger().WithGroup("archiveInventory")
+
+	policy := DeletePolicy(collection)
+	l.Debug("Archiving inventory of deleted record", "collection", collection, "record_id", e.Record.Id, "policy", policy)
+
+	err := e.App.RunInTransaction(func(txPb core.App) error {
+		filter := dbx.HashExp{relation: e.Record.Id, "archived": false}
+
+		rows, err := txPb.FindAllRecords("outpost_commodities", filter)
+		if err != nil {
+			return fmt.Errorf("finding outpost commodities: %w", err)
+		}
+
+		if policy == DeletePolicyBlock {
+			var stocked int
+			for _, row := range rows {
+				if row.GetFloat("amount") != 0 {
+					stocked++
+				}
+			}
+			if stocked > 0 {
+				l.Info("Blocking deletion of record with stock", "collection", collection, "record_id", e.Record.Id, "stocked", stocked)
+				return apis.NewApiError(
+					http.StatusConflict,
+					fmt.Sprintf("Can't delete the record while %d outpost commodities still hold stock, empty them first.", stocked),
+					map[string]any{"code": "stock_not_empty", "stocked": stocked},
+				)
+			}
+		}
+
+		changes, err := txPb.FindAllRecords("outpost_commodity_changes", filter)
+		if err != nil {
+			return fmt.Errorf("finding outpost commodity changes: %w", err)
+		}
+
+		records := append(rows, changes...)
+
+		outpostNames, err := recordNames(txPb, "outposts", records, "outpost")
+		if err != nil {
+			return err
+		}
+		commodityNames, err := recordNames(txPb, "commodities", records, "commodity")
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, record := range records {
+			record.Set("outpost_name", outpostNames[record.GetString("outpost")])
+			record.Set("commodity_name", commodityNames[record.GetString("commodity")])
+			record.Set("archived", true)
+			record.Set("archived_at", now)
+
+			if err := txPb.Save(record); err != nil {
+				l.Error("Failed to archive inventory record", "collection", record.Collection().Name, "record_id", record.Id, "error", err.Error())
+				return fmt.Errorf("archiving %s %s: %w", record.Collection().Name, record.Id, err)
+			}
+		}
+
+		l.Info("Archived inventory of deleted record", "collection", collection, "record_id", e.Record.Id, "outpost_commodities", len(rows), "changes", len(changes))
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("archiving inventory of %s %s: %w", collection, e.Record.Id, err)
+	}
+
+	return nil
+}
+
+// recordNames returns the names of the records of the collection referenced by
+// the relation field of the given records, by id. Only those records are loaded.
+func recordNames(app core.App, collection string, records []*core.Record, field string) (map[string]string, error) {
+	seen := map[string]bool{}
+	var ids []string
+	for _, record := range records {
+		if id := record.GetString(field); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+
+	referenced, err := app.FindRecordsByIds(collection, ids)
+	if err != nil {
+		return nil, fmt.Errorf("finding %s: %w", collection, err)
+	}
+
+	for _, record := range referenced {
+		names[record.Id] = record.GetString("name")
+	}
+	return names, nil
+}
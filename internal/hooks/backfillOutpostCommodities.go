@@ -0,0 +1,225 @@
+package hooks
+
+import (
+	"fmt"
+
+	"pulsepoint/internal/errs"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Outposts tracking every commodity keep an outpost_commodities row for every active commodity,
+// so their stock lists the whole catalogue: the rows are created with the outpost or when it
+// switches to tracking all commodities (BackfillOutpost), and for every new commodity
+// (BackfillOutpostCommodities). The other tracking modes create their rows on demand,
+// when the stock of a commodity first changes (see AdjustInventory).
+const trackingAllFilter = "tracking = '' || tracking = {:all}"
+
+// BackfillOutpostCommodities is a hook function that creates a zero-amount outpost commodity record for
+// every outpost tracking all commodities whenever a new commodity is created, e.g. by tasks.UpdateCommodities
+// after a patch added a new ore. The outposts tracking some types or a list get their row on demand.
+// Bound to OnRecordCreateExecute, it runs in the transaction creating the commodity (for the sync,
+// the sync transaction), so the commodity and its outpost commodities are committed together.
+//
+// Parameters:
+//
+//	e (*core.RecordEvent): The event that triggered this hook, containing the newly created commodity record.
+//
+// Returns:
+//
+//	error: The wrapped error of the failed step, the caller should fail the commodity creation with it.
+func BackfillOutpostCommodities(e *core.RecordEvent) error {
+	l := e.App.Logger().WithGroup("backfillOutpostCommodities")
+
+	// An archived commodity is no longer in the game, nothing to track
+	if e.Record.GetBool("archived") {
+		return nil
+	}
+
+	err := e.App.RunInTransaction(func(txPb core.App) error {
+		outpostCommodityCollection, err := txPb.FindCollectionByNameOrId("outpost_commodities")
+		if err != nil {
+			l.Error("Error finding outpost_commodities collection", "error", err)
+			return fmt.Errorf("%w: outpost_commodities: %w", errs.ErrCollectionMissing, err)
+		}
+
+		outposts, err := txPb.FindRecordsByFilter("outposts", trackingAllFilter, "", 0, 0, dbx.Params{"all": TrackingAll})
+		if err != nil {
+			l.Error("Error finding outposts", "error", err)
+			return fmt.Errorf("finding outposts: %w", err)
+		}
+
+		for _, outpost := range outposts {
+			if err := txPb.Save(newOutpostCommodity(outpostCommodityCollection, outpost, e.Record.Id)); err != nil {
+				l.Error("Failed to save new outpost commodity", "error", err.Error(), "outpost_id", outpost.Id, "commodity_id", e.Record.Id)
+				return fmt.Errorf("saving outpost commodity for outpost %s: %w", outpost.Id, err)
+			}
+		}
+
+		l.Info("Backfilled outpost commodities for new commodity", "commodity_id", e.Record.Id, "outposts_count", len(outposts))
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("backfilling outpost commodities for commodity %s: %w", e.Record.Id, err)
+	}
+
+	return nil
+}
+
+// BackfillOutpost is a hook function that creates a zero-amount outpost commodity record for every
+// active commodity the outpost has no row for yet, when the outpost is created tracking all commodities
+// or switched to tracking all of them. Any other save of the outpost is left alone.
+// Bound to OnRecordCreateExecute and OnRecordUpdateExecute, it saves the outpost itself (e.Next) and
+// runs in the transaction saving it, so the outpost and its outpost commodities are committed together.
+//
+// Parameters:
+//
+//	e (*core.RecordEvent): The event that triggered this hook, containing the outpost record being saved.
+//
+// Returns:
+//
+//	error: The wrapped error of the failed step, the caller should fail the outpost save with it.
+func BackfillOutpost(e *core.RecordEvent) error {
+	l := e.App.Logger().WithGroup("backfillOutpost")
+
+	// The stored mode is only known before the save, it becomes the original afterwards
+	wasTrackingAll := !e.Record.IsNew() && tracksAll(e.Record.Original())
+
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	if wasTrackingAll || !tracksAll(e.Record) {
+		return nil
+	}
+
+	outpostCommodityCollection, err := e.App.FindCollectionByNameOrId("outpost_commodities")
+	if err != nil {
+		l.Error("Error finding outpost_commodities collection", "error", err)
+		return fmt.Errorf("%w: outpost_commodities: %w", errs.ErrCollectionMissing, err)
+	}
+
+	commodities, err := e.App.FindRecordsByFilter("commodities", "archived = false", "", 0, 0)
+	if err != nil {
+		return fmt.Errorf("finding commodities: %w", err)
+	}
+
+	// An outpost switched to tracking all commodities already has the rows of the ones it tracked
+	existing, err := e.App.FindRecordsByFilter("outpost_commodities", "outpost = {:outpost} && archived = false", "", 0, 0, dbx.Params{"outpost": e.Record.Id})
+	if err != nil {
+		return fmt.Errorf("finding outpost commodities of outpost %s: %w", e.Record.Id, err)
+	}
+	tracked := make(map[string]bool, len(existing))
+	for _, outpostCommodity := range existing {
+		tracked[outpostCommodity.GetString("commodity")] = true
+	}
+
+	var created int
+	for _, commodity := range commodities {
+		if tracked[commodity.Id] {
+			continue
+		}
+
+		if err := e.App.Save(newOutpostCommodity(outpostCommodityCollection, e.Record, commodity.Id)); err != nil {
+			l.Error("Failed to save new outpost commodity", "error", err.Error(), "outpost_id", e.Record.Id, "commodity_id", commodity.Id)
+			return fmt.Errorf("saving outpost commodity for outpost %s and commodity %s: %w", e.Record.Id, commodity.Id, err)
+		}
+		created++
+	}
+
+	l.Info("Backfilled outpost commodities for outpost tracking all commodities", "outpost_id", e.Record.Id, "created", created)
+
+	return nil
+}
+
+// RepairOutpostCommodities creates the missing zero-amount outpost commodity record of every
+// (outpost tracking all commodities, active commodity) pair, in one transaction, e.g. for the
+// rows deleted through the records API or the outposts saved before the backfill hooks existed.
+// Rows are never created for the commodities an outpost doesn't track, nor deleted.
+//
+// Parameters:
+//
+//	app (core.App): The application to repair.
+//	dryRun (bool): When true, only the missing pairs are counted and nothing is written.
+//
+// Returns:
+//
+//	int: The number of missing pairs, created unless dryRun is set.
+//	error: The wrapped error of the failed step, nothing is written then.
+func RepairOutpostCommodities(app core.App, dryRun bool) (int, error) {
+	l := app.Logger().WithGroup("repairOutpostCommodities")
+
+	var missing int
+	err := app.RunInTransaction(func(txPb core.App) error {
+		outpostCommodityCollection, err := txPb.FindCollectionByNameOrId("outpost_commodities")
+		if err != nil {
+			l.Error("Error finding outpost_commodities collection", "error", err)
+			return fmt.Errorf("%w: outpost_commodities: %w", errs.ErrCollectionMissing, err)
+		}
+
+		outposts, err := txPb.FindRecordsByFilter("outposts", trackingAllFilter, "", 0, 0, dbx.Params{"all": TrackingAll})
+		if err != nil {
+			return fmt.Errorf("finding outposts: %w", err)
+		}
+
+		commodities, err := txPb.FindRecordsByFilter("commodities", "archived = false", "", 0, 0)
+		if err != nil {
+			return fmt.Errorf("finding commodities: %w", err)
+		}
+
+		existing, err := txPb.FindRecordsByFilter("outpost_commodities", "archived = false", "", 0, 0)
+		if err != nil {
+			return fmt.Errorf("finding outpost commodities: %w", err)
+		}
+		tracked := make(map[string]bool, len(existing))
+		for _, outpostCommodity := range existing {
+			tracked[outpostCommodity.GetString("outpost")+"/"+outpostCommodity.GetString("commodity")] = true
+		}
+
+		for _, outpost := range outposts {
+			for _, commodity := range commodities {
+				if tracked[outpost.Id+"/"+commodity.Id] {
+					continue
+				}
+
+				missing++
+				if dryRun {
+					continue
+				}
+
+				l.Debug("Creating missing outpost commodity", "outpost_id", outpost.Id, "commodity_id", commodity.Id)
+				if err := txPb.Save(newOutpostCommodity(outpostCommodityCollection, outpost, commodity.Id)); err != nil {
+					l.Error("Failed to save missing outpost commodity", "error", err.Error(), "outpost_id", outpost.Id, "commodity_id", commodity.Id)
+					return fmt.Errorf("saving outpost commodity for outpost %s and commodity %s: %w", outpost.Id, commodity.Id, err)
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("repairing outpost commodities: %w", err)
+	}
+
+	l.Info("Repaired outpost commodities", "missing", missing, "dry_run", dryRun)
+
+	return missing, nil
+}
+
+// tracksAll reports whether the outpost tracks all commodities, the default of an outpost without a mode.
+func tracksAll(outpost *core.Record) bool {
+	mode := outpost.GetString("tracking")
+	return mode == "" || mode == TrackingAll
+}
+
+// newOutpostCommodity builds the zero-amount outpost commodity record of the outpost and the commodity.
+func newOutpostCommodity(collection *core.Collection, outpost *core.Record, commodityId string) *core.Record {
+	outpostCommodity := core.NewRecord(collection)
+	outpostCommodity.Set("organization", outpost.GetString("organization"))
+	outpostCommodity.Set("outpost", outpost.Id)
+	outpostCommodity.Set("commodity", commodityId)
+	outpostCommodity.Set("amount", 0)
+	return outpostCommodity
+}
@@ -0,0 +1,39 @@
+package hooks
+
+import (
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// BlockDirectAmountWrites is an OnRecordCreateRequest and OnRecordUpdateRequest hook of the
+// "outpost_commodities" collection rejecting any amount written through the records API.
+// The API rules already keep members out, this hook covers the superusers (e.g. from the
+// dashboard): every stock change goes through AdjustInventory, which records it in the
+// ledger with its actor, reason, note and reference. Other fields can still be edited.
+//
+// Parameters:
+//
+//	e (*core.RecordRequestEvent): The event of the request, e.Record holding the submitted values.
+//
+// Returns:
+//
+//	error: A 400 error with an "amount" field error when the request changes the amount.
+func BlockDirectAmountWrites(e *core.RecordRequestEvent) error {
+	previous := 0.0
+	if !e.Record.IsNew() {
+		previous = e.Record.Original().GetFloat("amount")
+	}
+
+	if e.Record.GetFloat("amount") != previous {
+		e.App.Logger().WithGroup("blockDirectAmountWrites").Warn("Rejected a direct amount write",
+			"record_id", e.Record.Id,
+			"outpost_id", e.Record.GetString("outpost"),
+			"commodity_id", e.Record.GetString("commodity"))
+
+		return e.BadRequestError("The stock can only change through the inventory adjust endpoint.", validation.Errors{
+			"amount": validation.NewError("validation_amount_read_only", "Use POST /api/pulsepoint/outposts/{id}/inventory/adjust to change the stock."),
+		})
+	}
+
+	return e.Next()
+}
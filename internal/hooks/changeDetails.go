@@ -0,0 +1,64 @@
+package hooks
+
+import (
+	"fmt"
+	"slices"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ChangeReasons are the reasons a stock can change for.
+var ChangeReasons = []string{"mined", "refined", "bought", "sold", "transferred", "adjusted", "lost"}
+
+// referenceCollections are the collections a stock change can refer to,
+// e.g. the outpost of a transfer or the terminal of a sale.
+var referenceCollections = []string{
+	"outposts",
+	"outpost_commodity_changes",
+	"terminals",
+	"space_stations",
+	"cities",
+	"surface_outposts",
+}
+
+// maxNoteLength is the maximum length of the note of a stock change.
+const maxNoteLength = 500
+
+// ChangeDetails describe a stock change beyond its amount: who made it, why and what it relates to.
+type ChangeDetails struct {
+	Actor               string
+	Reason              string
+	Note                string
+	ReferenceCollection string
+	ReferenceID         string
+}
+
+// validateChangeDetails checks the details of a change given by a request.
+func validateChangeDetails(app core.App, details ChangeDetails) error {
+	fieldErrors := validation.Errors{}
+
+	if details.Reason != "" && !slices.Contains(ChangeReasons, details.Reason) {
+		fieldErrors["reason"] = validation.NewError("validation_invalid_reason", "Unknown reason.")
+	}
+
+	if len([]rune(details.Note)) > maxNoteLength {
+		fieldErrors["note"] = validation.NewError("validation_note_too_long", fmt.Sprintf("The note can't be longer than %d characters.", maxNoteLength))
+	}
+
+	switch {
+	case details.ReferenceCollection == "" && details.ReferenceID == "":
+	case !slices.Contains(referenceCollections, details.ReferenceCollection):
+		fieldErrors["reference_collection"] = validation.NewError("validation_invalid_reference", "A change can't refer to this collection.")
+	default:
+		if _, err := app.FindRecordById(details.ReferenceCollection, details.ReferenceID); err != nil {
+			fieldErrors["reference_id"] = validation.NewError("validation_invalid_reference", "The referenced record doesn't exist.")
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+
+	return nil
+}
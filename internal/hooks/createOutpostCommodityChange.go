@@ -1,66 +1,70 @@
 package hooks
 
-import "github.com/pocketbase/pocketbase/core"
+import (
+	"fmt"
 
-// CreateCommodityChanges is a hook function that tracks and records changes in the commodity quantity
-// for an outpost whenever a commodity record is updated. It compares the new commodity quantity with the previous
-// quantity and logs the difference, saving this change as a new entry in the "outpost_commodity_changes" collection.
+	"pulsepoint/internal/errs"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RecordCommodityChange appends a change of the amount of an outpost commodity to the "outpost_commodity_changes"
+// ledger, with who made it and why. The ledger is the source of truth of the stock: AdjustInventory records
+// the change before updating the amount, in the same transaction, and tasks.ReconcileInventory recomputes
+// the amounts from the ledger.
 //
 // Parameters:
-//   e (*core.RecordEvent): The event that triggered this hook, containing the updated commodity record.
+//   app (core.App): The application, or the transaction the amount is updated in.
+//   outpostCommodity (*core.Record): The outpost commodity whose amount changes.
+//   quantityChange (float64): The change of the amount.
+//   details (ChangeDetails): Who made the change, why and what it relates to.
 //
-// Logs:
-//   Detailed logs are created to capture:
-//   - The start of the transaction.
-//   - The old and new commodity records.
-//   - The calculated quantity change.
-func CreateCommodityChanges(e *core.RecordEvent) {
-	l := e.App.Logger().WithGroup("createOutpostCommodityChange")
+// Returns:
+//   error: The wrapped error of the failed step, the caller should fail the adjustment with it.
+func RecordCommodityChange(app core.App, outpostCommodity *core.Record, quantityChange float64, details ChangeDetails) error {
+	l := app.Logger().WithGroup("createOutpostCommodityChange")
 
 	// Start the transaction to ensure atomicity.
-	l.Debug("Starting transaction to create commodity changes", "outpost_id", e.Record.Id)
-
-	e.App.RunInTransaction(func(txPb core.App) error {
-		// Retrieve the new and previous records to compare changes
-		original := e.Record.Original().Clone()
-
-		// Log both old and new commodity records for debugging purposes
-		l.Debug("Old outpost commodity record", "old_record", original)
-		l.Debug("New outpost commodity record", "new_record", e.Record)
+	l.Debug("Starting transaction to create commodity changes", "outpost_commodity_id", outpostCommodity.Id)
 
+	err := app.RunInTransaction(func(txPb core.App) error {
 		// Retrieve the commodity_changes collection to store the change record
 		commodityChangesCollection, err := txPb.FindCollectionByNameOrId("outpost_commodity_changes")
 		if err != nil {
 			l.Error("Error finding commodity_changes collection", "error", err)
-			return err
+			return fmt.Errorf("%w: outpost_commodity_changes: %w", errs.ErrCollectionMissing, err)
 		}
 
 		// Create a new record for the commodity change
 		commodityChangeRecord := core.NewRecord(commodityChangesCollection)
-		commodityChangeRecord.Set("organization", e.Record.GetString("organization"))
-		commodityChangeRecord.Set("outpost", e.Record.GetString("outpost"))
-		commodityChangeRecord.Set("outpost_commodity", e.Record.Id)
-		commodityChangeRecord.Set("commodity", e.Record.Get("commodity"))
-
-		// Calculate the change in quantity by comparing the new and previous values
-		newAmount := e.Record.GetFloat("amount")
-		previousAmount := original.GetFloat("amount")
-		quantityChange := newAmount - previousAmount
-
-		// Log the new and previous amounts for clarity
-		l.Debug("Commodity quantity change", "new_amount", newAmount, "previous_amount", previousAmount, "quantity_change", quantityChange)
+		commodityChangeRecord.Set("organization", outpostCommodity.GetString("organization"))
+		commodityChangeRecord.Set("outpost", outpostCommodity.GetString("outpost"))
+		commodityChangeRecord.Set("outpost_commodity", outpostCommodity.Id)
+		commodityChangeRecord.Set("commodity", outpostCommodity.Get("commodity"))
 
 		// Set the quantity change in the new record
 		commodityChangeRecord.Set("change_amount", quantityChange)
 
+		// Who made the change and why
+		commodityChangeRecord.Set("actor", details.Actor)
+		commodityChangeRecord.Set("reason", details.Reason)
+		commodityChangeRecord.Set("note", details.Note)
+		commodityChangeRecord.Set("reference_collection", details.ReferenceCollection)
+		commodityChangeRecord.Set("reference_id", details.ReferenceID)
+
 		// Save the commodity change record to the database
 		if err := txPb.Save(commodityChangeRecord); err != nil {
 			l.Error("Failed to save commodity change record", "error", err.Error())
-			return err
+			return fmt.Errorf("saving commodity change: %w", err)
 		}
 
-		l.Info("Successfully created commodity change record", "outpost_commodity_id", e.Record.Id, "commodity_id", e.Record.Get("commodity"))
+		l.Info("Successfully created commodity change record", "outpost_commodity_id", outpostCommodity.Id, "commodity_id", outpostCommodity.Get("commodity"))
 
 		return nil
 	})
+	if err != nil {
+		return fmt.Errorf("recording change of outpost commodity %s: %w", outpostCommodity.Id, err)
+	}
+
+	return nil
 }
@@ -0,0 +1,151 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Tracking modes of an outpost, the commodities it keeps a stock of.
+const (
+	TrackingAll   = "all"
+	TrackingTypes = "types"
+	TrackingList  = "list"
+)
+
+// TracksCommodity reports whether the outpost tracks the commodity according to its tracking mode.
+// An outpost without a mode tracks every commodity. Archived commodities are never tracked.
+//
+// Parameters:
+//
+//	outpost (*core.Record): The outpost record.
+//	commodity (*core.Record): The commodity record.
+//
+// Returns:
+//
+//	bool: Returns true if the outpost tracks the commodity.
+func TracksCommodity(outpost *core.Record, commodity *core.Record) bool {
+	if commodity.GetBool("archived") {
+		return false
+	}
+
+	switch outpost.GetString("tracking") {
+	case TrackingTypes:
+		return slices.ContainsFunc(trackedTypes(outpost), func(t string) bool {
+			return strings.EqualFold(t, commodity.GetString("type"))
+		})
+	case TrackingList:
+		return slices.Contains(outpost.GetStringSlice("tracked_commodities"), commodity.Id)
+	default:
+		return true
+	}
+}
+
+// trackedTypes returns the commodity types tracked by the outpost, stored as a JSON array of strings.
+func trackedTypes(outpost *core.Record) []string {
+	var types []string
+	raw, _ := json.Marshal(outpost.Get("tracked_types"))
+	if err := json.Unmarshal(raw, &types); err != nil {
+		return nil
+	}
+	return types
+}
+
+// ValidateOutpostTracking is an OnRecordValidate hook of the "outposts" collection checking that
+// the tracking mode of an outpost comes with what it needs: at least one commodity type for
+// "types", at least one commodity for "list". Changing the mode later never deletes the
+// outpost_commodities rows or their changes, the stock of untracked commodities is kept.
+//
+// Parameters:
+//
+//	e (*core.RecordEvent): The event of the outpost being created or updated.
+//
+// Returns:
+//
+//	error: validation.Errors keyed by the missing field, so the API answers 400 with the field errors.
+func ValidateOutpostTracking(e *core.RecordEvent) error {
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	fieldErrors := validation.Errors{}
+
+	switch e.Record.GetString("tracking") {
+	case TrackingTypes:
+		if len(trackedTypes(e.Record)) == 0 {
+			fieldErrors["tracked_types"] = validation.NewError("validation_required", "Choose at least one commodity type to track.")
+		}
+	case TrackingList:
+		if len(e.Record.GetStringSlice("tracked_commodities")) == 0 {
+			fieldErrors["tracked_commodities"] = validation.NewError("validation_required", "Choose at least one commodity to track.")
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+
+	return nil
+}
+
+// ValidateOutpostCommodity is an OnRecordValidate hook of the "outpost_commodities" collection for the
+// rows created on demand, when the stock of a commodity at an outpost first changes, or upfront
+// for the outposts tracking all commodities (see BackfillOutpost and BackfillOutpostCommodities).
+// The row inherits the organization of its outpost, and the outpost must track the commodity.
+// Existing rows are left alone, they keep their stock when the tracking of the outpost changes.
+//
+// Parameters:
+//
+//	e (*core.RecordEvent): The event of the outpost commodity being created or updated.
+//
+// Returns:
+//
+//	error: validation.Errors when the outpost doesn't track the commodity, or the error of the lookups.
+func ValidateOutpostCommodity(e *core.RecordEvent) error {
+	if !e.Record.IsNew() {
+		return e.Next()
+	}
+
+	l := e.App.Logger().WithGroup("validateOutpostCommodity")
+
+	// The relations are optional for the archived rows only, see ArchiveInventory
+	fieldErrors := validation.Errors{}
+	for _, field := range []string{"outpost", "commodity"} {
+		if e.Record.GetString(field) == "" {
+			fieldErrors[field] = validation.NewError("validation_required", "Cannot be blank.")
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return fieldErrors
+	}
+
+	outpostId := e.Record.GetString("outpost")
+
+	outpost, err := e.App.FindRecordById("outposts", outpostId)
+	if err != nil {
+		return validation.Errors{"outpost": validation.NewError("validation_invalid_outpost", "Unknown outpost.")}
+	}
+
+	// The organization always comes from the outpost, it is what the access rules check
+	e.Record.Set("organization", outpost.GetString("organization"))
+
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	commodity, err := e.App.FindRecordById("commodities", e.Record.GetString("commodity"))
+	if err != nil {
+		l.Error("Failed to find commodity", "commodity_id", e.Record.GetString("commodity"), "error", err.Error())
+		return fmt.Errorf("finding commodity %s: %w", e.Record.GetString("commodity"), err)
+	}
+
+	if !TracksCommodity(outpost, commodity) {
+		return validation.Errors{"commodity": validation.NewError("validation_commodity_not_tracked", "The outpost doesn't track this commodity.")}
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package hooks
+
+import (
+	"fmt"
+
+	"pulsepoint/internal/errs"
+	"pulsepoint/internal/tasks"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// PriceSourceUex is the source of the prices synced from UEX.
+const PriceSourceUex = "uex"
+
+// RecordCommodityPriceHistory is a handler of tasks.OnRecordSave that appends a row to the
+// "commodity_price_history" collection whenever the sync creates a commodity or changes its prices,
+// so past prices are kept for charts and trend analysis.
+// The row is written in the sync transaction: a commodity is never saved without its history row.
+// The prices are observed when UEX last reported them, or now when UEX didn't say.
+//
+// Parameters:
+//
+//	e (*tasks.RecordChangeEvent): The change of a synced record, e.App being the sync transaction.
+//
+// Returns:
+//
+//	error: The wrapped error of the failed step, failing the sync transaction. Changes of other records or fields are ignored.
+func RecordCommodityPriceHistory(e *tasks.RecordChangeEvent) error {
+	if e.Collection != "commodities" {
+		return e.Next()
+	}
+
+	_, buyChanged := e.Changes["price_buy"]
+	_, sellChanged := e.Changes["price_sell"]
+	if !buyChanged && !sellChanged {
+		return e.Next()
+	}
+
+	l := e.App.Logger().WithGroup("recordCommodityPriceHistory")
+
+	// Only the changed fields are in the event, the saved commodity has both current prices
+	commodity := e.Record
+
+	collection, err := e.App.FindCollectionByNameOrId("commodity_price_history")
+	if err != nil {
+		l.Error("Error finding commodity_price_history collection", "error", err)
+		return fmt.Errorf("%w: commodity_price_history: %w", errs.ErrCollectionMissing, err)
+	}
+
+	observedAt := commodity.GetDateTime("reported_at")
+	if observedAt.IsZero() {
+		observedAt = types.NowDateTime()
+	}
+
+	entry := core.NewRecord(collection)
+	entry.Set("commodity", commodity.Id)
+	entry.Set("price_buy", commodity.GetFloat("price_buy"))
+	entry.Set("price_sell", commodity.GetFloat("price_sell"))
+	entry.Set("source", PriceSourceUex)
+	entry.Set("observed_at", observedAt)
+
+	if err := e.App.Save(entry); err != nil {
+		l.Error("Failed to save price history entry", "commodity_id", commodity.Id, "error", err.Error())
+		return fmt.Errorf("saving price history of commodity %s: %w", commodity.Id, err)
+	}
+
+	l.Debug("Recorded commodity price",
+		"commodity_id", commodity.Id,
+		"price_buy", commodity.GetFloat("price_buy"),
+		"price_sell", commodity.GetFloat("price_sell"),
+		"observed_at", observedAt.String())
+
+	return e.Next()
+}
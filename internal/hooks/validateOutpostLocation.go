@@ -0,0 +1,69 @@
+package hooks
+
+import (
+	"fmt"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// ValidateOutpostLocation is an OnRecordValidate hook of the "outposts" collection that keeps the
+// location relations of an outpost consistent: the moon must orbit the planet and the planet
+// must belong to the star system. Missing parents are filled in from the most precise relation,
+// e.g. an outpost created with only a moon gets its planet and star system.
+//
+// Parameters:
+//
+//	e (*core.RecordEvent): The event of the outpost being created or updated.
+//
+// Returns:
+//
+//	error: validation.Errors keyed by the inconsistent field, so the API answers 400 with the field
+//	errors, or the error of the lookups.
+func ValidateOutpostLocation(e *core.RecordEvent) error {
+	// The default validation checks first that the related records exist
+	if err := e.Next(); err != nil {
+		return err
+	}
+
+	l := e.App.Logger().WithGroup("validateOutpostLocation")
+
+	fieldErrors := validation.Errors{}
+
+	if moonId := e.Record.GetString("moon"); moonId != "" {
+		moon, err := e.App.FindRecordById("moons", moonId)
+		if err != nil {
+			l.Error("Failed to find moon", "moon_id", moonId, "error", err.Error())
+			return fmt.Errorf("finding moon %s: %w", moonId, err)
+		}
+
+		switch planet := e.Record.GetString("planet"); {
+		case planet == "":
+			e.Record.Set("planet", moon.GetString("planet"))
+		case planet != moon.GetString("planet"):
+			fieldErrors["moon"] = validation.NewError("validation_moon_not_on_planet", "The moon doesn't orbit the selected planet.")
+		}
+	}
+
+	if planetId := e.Record.GetString("planet"); planetId != "" {
+		planet, err := e.App.FindRecordById("planets", planetId)
+		if err != nil {
+			l.Error("Failed to find planet", "planet_id", planetId, "error", err.Error())
+			return fmt.Errorf("finding planet %s: %w", planetId, err)
+		}
+
+		switch system := e.Record.GetString("star_system"); {
+		case system == "":
+			e.Record.Set("star_system", planet.GetString("star_system"))
+		case system != planet.GetString("star_system"):
+			fieldErrors["planet"] = validation.NewError("validation_planet_not_in_system", "The planet isn't in the selected star system.")
+		}
+	}
+
+	if len(fieldErrors) > 0 {
+		l.Debug("Rejecting inconsistent outpost location", "outpost_id", e.Record.Id, "errors", fieldErrors.Error())
+		return fieldErrors
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"pulsepoint/internal/hooks"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// adjustInventoryBody is the body of an inventory adjustment request.
+type adjustInventoryBody struct {
+	Changes             []hooks.Adjustment `json:"changes"`
+	Reason              string             `json:"reason"`
+	Note                string             `json:"note"`
+	ReferenceCollection string             `json:"reference_collection"`
+	ReferenceID         string             `json:"reference_id"`
+}
+
+// AdjustInventory returns a handler applying stock changes to the outpost in the path, see hooks.AdjustInventory.
+// This is the only way for members to change a stock: the ledger entry and the new amount are written
+// in one transaction, so concurrent changes can't lose each other.
+//
+// Body:
+//   - changes: the deltas, e.g. [{"commodity": "<id>", "delta": -12}]
+//   - reason: mined, refined, bought, sold, transferred, adjusted (default) or lost
+//   - note: an optional free text
+//   - reference_collection, reference_id: an optional related record, e.g. the outpost of a transfer
+func AdjustInventory() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		l := e.App.Logger().WithGroup("routes")
+
+		outpost, err := findMemberOutpost(e, e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Outpost not found.", err)
+		}
+
+		var body adjustInventoryBody
+		if err := e.BindBody(&body); err != nil {
+			return e.BadRequestError("Invalid body.", err)
+		}
+
+		details := hooks.ChangeDetails{
+			Reason:              body.Reason,
+			Note:                body.Note,
+			ReferenceCollection: body.ReferenceCollection,
+			ReferenceID:         body.ReferenceID,
+		}
+		if e.Auth != nil && e.Auth.Collection().Name == "users" {
+			details.Actor = e.Auth.Id
+		}
+
+		updated, err := hooks.AdjustInventory(e.App, outpost, body.Changes, details)
+		if err != nil {
+			var fieldErrors validation.Errors
+			if errors.As(err, &fieldErrors) {
+				return e.BadRequestError("Invalid inventory adjustment.", fieldErrors)
+			}
+
+			l.Error("Failed to adjust inventory", "outpost_id", outpost.Id, "error", err.Error())
+			return e.InternalServerError("Failed to adjust the inventory.", err)
+		}
+
+		stock := make([]OutpostStock, 0, len(updated))
+		for _, outpostCommodity := range updated {
+			item := OutpostStock{
+				Commodity: outpostCommodity.GetString("commodity"),
+				Amount:    outpostCommodity.GetFloat("amount"),
+			}
+			if commodity, err := e.App.FindRecordById("commodities", item.Commodity); err == nil {
+				item.Name = commodity.GetString("name")
+				item.Code = commodity.GetString("code")
+			}
+			stock = append(stock, item)
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"outpost": outpost.Id,
+			"stock":   stock,
+		})
+	}
+}
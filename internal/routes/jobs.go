@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"pulsepoint/internal/errs"
+	"pulsepoint/internal/tasks"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// StartTask returns a handler that enqueues the given task and immediately answers
+// with the job id of the run (202 Accepted). When the task is already running,
+// the request is coalesced into the running job and its id is returned instead,
+// unless that job is a dry run: the request then fails with 409 Conflict.
+//
+// With ?wait=true the handler blocks until the run finished and answers with its
+// status, using the HTTP status matching the error the task failed with (see errs.HTTPStatus).
+//
+// With ?dryRun=true the task runs synchronously without committing anything and the
+// handler answers with the diff of what it would have written (see dryRunTask).
+//
+// With ?stage=<name> only the named stage of a staged task runs, e.g. ?stage=space_stations.
+func StartTask(runner *tasks.Runner, name string, fn tasks.TaskFunc) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		l := e.App.Logger().WithGroup("routes")
+
+		fn := fn
+		if stage := e.Request.URL.Query().Get("stage"); stage != "" {
+			stageFn, ok := tasks.LookupStage(name, stage)
+			if !ok {
+				return e.BadRequestError("Unknown stage.", map[string]string{"stage": stage})
+			}
+			fn = stageFn
+		}
+
+		if e.Request.URL.Query().Get("dryRun") == "true" {
+			return dryRunTask(e, runner, name, fn)
+		}
+
+		run, started, err := runner.Start(name, tasks.TriggerManual, fn)
+		switch {
+		case errors.Is(err, tasks.ErrAlreadyRunning):
+			// Only a running dry run is not coalesced into, it would commit nothing
+			return e.Error(http.StatusConflict, "A dry run of the task is running.", nil)
+		case errors.Is(err, tasks.ErrRunnerStopped):
+			return e.Error(http.StatusServiceUnavailable, "The application is shutting down.", nil)
+		case err != nil:
+			l.Error("Failed to start task", "task", name, "error", err.Error())
+			return e.Error(errs.HTTPStatus(err), "Failed to start the task.", map[string]string{"code": errs.Code(err)})
+		}
+
+		l.Info("Task enqueued", "task", name, "job_id", run.ID(), "coalesced", !started)
+
+		if e.Request.URL.Query().Get("wait") == "true" {
+			select {
+			case <-run.Done():
+			case <-e.Request.Context().Done():
+				// The client went away, the task keeps running in the background
+				return nil
+			}
+
+			return e.JSON(errs.HTTPStatus(run.Err()), run.Snapshot())
+		}
+
+		return e.JSON(http.StatusAccepted, map[string]any{
+			"success":   true,
+			"jobId":     run.ID(),
+			"coalesced": !started,
+		})
+	}
+}
+
+// dryRunTask runs the task as a dry run and answers with its status, including the diff.
+// A dry run is never coalesced into a running task: it answers 409 Conflict instead.
+func dryRunTask(e *core.RequestEvent, runner *tasks.Runner, name string, fn tasks.TaskFunc) error {
+	l := e.App.Logger().WithGroup("routes")
+
+	l.Info("Starting dry run", "task", name)
+
+	run, err := runner.DryRun(name, tasks.TriggerManual, fn)
+	switch {
+	case errors.Is(err, tasks.ErrAlreadyRunning):
+		return e.Error(http.StatusConflict, "The task is already running.", nil)
+	case errors.Is(err, tasks.ErrRunnerStopped):
+		return e.Error(http.StatusServiceUnavailable, "The application is shutting down.", nil)
+	case err != nil:
+		l.Error("Failed to start dry run", "task", name, "error", err.Error())
+		return e.Error(errs.HTTPStatus(err), "Failed to start the task.", map[string]string{"code": errs.Code(err)})
+	}
+
+	return e.JSON(errs.HTTPStatus(run.Err()), run.Snapshot())
+}
+
+// GetJob returns a handler reporting the progress, final status and error details of a job.
+// Running jobs are reported live from the runner, finished ones from their sync_runs record,
+// the diff of a dry run included.
+func GetJob(runner *tasks.Runner) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		id := e.Request.PathValue("id")
+
+		if run := runner.Find(id); run != nil {
+			return e.JSON(http.StatusOK, run.Snapshot())
+		}
+
+		record, err := e.App.FindRecordById("sync_runs", id)
+		if err != nil {
+			return e.NotFoundError("Job not found.", err)
+		}
+
+		return e.JSON(http.StatusOK, tasks.SyncRunStatusFromRecord(record))
+	}
+}
+
+// CancelTask returns a handler aborting the running instance of the task named in the path.
+func CancelTask(runner *tasks.Runner) func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		name := e.Request.PathValue("name")
+
+		e.App.Logger().WithGroup("routes").Info("Received request to cancel task", "task", name)
+
+		if !runner.Cancel(name) {
+			return e.NotFoundError("Task is not running.", nil)
+		}
+
+		return e.JSON(http.StatusOK, map[string]bool{"success": true})
+	}
+}
@@ -0,0 +1,239 @@
+package routes
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"pulsepoint/internal/hooks"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// outpostLocationFilters maps the supported query parameters to the outposts field they filter on.
+var outpostLocationFilters = map[string]string{
+	"organization": "organization",
+	"starSystem":   "star_system",
+	"planet":       "planet",
+	"moon":         "moon",
+	"region":       "region",
+}
+
+// OutpostStock is the amount of a commodity stored at an outpost.
+type OutpostStock struct {
+	Commodity string  `json:"commodity"`
+	Name      string  `json:"name"`
+	Code      string  `json:"code"`
+	Amount    float64 `json:"amount"`
+}
+
+// OutpostLocation is an outpost with its location and its stock.
+type OutpostLocation struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	Organization string         `json:"organization"`
+	StarSystem   string         `json:"starSystem"`
+	Planet       string         `json:"planet"`
+	Moon         string         `json:"moon"`
+	Region       string         `json:"region"`
+	Latitude     float64        `json:"latitude"`
+	Longitude    float64        `json:"longitude"`
+	Stock        []OutpostStock `json:"stock"`
+}
+
+// ListOutpostsByLocation returns a handler listing the outposts the user can see, with the
+// commodities in stock, optionally narrowed down to a location.
+// Users see the outposts of their organizations, superusers see every outpost.
+//
+// Query parameters:
+//   - starSystem, planet, moon: the id of the location the outposts must be at
+//   - region: the region the outposts must be in
+//   - organization: the id of the organization the outposts must belong to
+func ListOutpostsByLocation() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		l := e.App.Logger().WithGroup("routes")
+
+		query := e.Request.URL.Query()
+
+		filters := []string{}
+		params := dbx.Params{}
+		if !e.HasSuperuserAuth() {
+			filters = append(filters, "organization.members.id ?= {:user}")
+			params["user"] = e.Auth.Id
+		}
+		for param, field := range outpostLocationFilters {
+			if value := query.Get(param); value != "" {
+				filters = append(filters, field+" = {:"+param+"}")
+				params[param] = value
+			}
+		}
+		// Map iteration order is random, keep the filter deterministic
+		sort.Strings(filters)
+
+		outposts, err := e.App.FindRecordsByFilter("outposts", strings.Join(filters, " && "), "name", 0, 0, params)
+		if err != nil {
+			l.Error("Failed to get outposts", "error", err.Error())
+			return e.InternalServerError("Failed to list the outposts.", err)
+		}
+
+		result := make([]OutpostLocation, 0, len(outposts))
+		if len(outposts) == 0 {
+			return e.JSON(http.StatusOK, map[string]any{"outposts": result})
+		}
+
+		outpostIds := make([]any, len(outposts))
+		for i, outpost := range outposts {
+			outpostIds[i] = outpost.Id
+		}
+
+		stock, err := e.App.FindAllRecords("outpost_commodities", dbx.In("outpost", outpostIds...), dbx.NewExp("amount > 0"), dbx.HashExp{"archived": false})
+		if err != nil {
+			l.Error("Failed to get outpost stock", "error", err.Error())
+			return e.InternalServerError("Failed to list the outposts.", err)
+		}
+
+		// Only the commodities in stock are needed, for their names
+		seen := map[string]bool{}
+		var commodityIds []string
+		for _, entry := range stock {
+			if id := entry.GetString("commodity"); id != "" && !seen[id] {
+				seen[id] = true
+				commodityIds = append(commodityIds, id)
+			}
+		}
+
+		var commodities []*core.Record
+		if len(commodityIds) > 0 {
+			if commodities, err = e.App.FindRecordsByIds("commodities", commodityIds); err != nil {
+				l.Error("Failed to get commodities", "error", err.Error())
+				return e.InternalServerError("Failed to list the outposts.", err)
+			}
+		}
+		commoditiesById := make(map[string]*core.Record, len(commodities))
+		for _, commodity := range commodities {
+			commoditiesById[commodity.Id] = commodity
+		}
+
+		stockByOutpost := map[string][]OutpostStock{}
+		for _, entry := range stock {
+			item := OutpostStock{
+				Commodity: entry.GetString("commodity"),
+				Amount:    entry.GetFloat("amount"),
+			}
+			if commodity := commoditiesById[item.Commodity]; commodity != nil {
+				item.Name = commodity.GetString("name")
+				item.Code = commodity.GetString("code")
+			}
+			outpostId := entry.GetString("outpost")
+			stockByOutpost[outpostId] = append(stockByOutpost[outpostId], item)
+		}
+
+		for _, outpost := range outposts {
+			items := stockByOutpost[outpost.Id]
+			if items == nil {
+				items = []OutpostStock{}
+			}
+			sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+
+			result = append(result, OutpostLocation{
+				ID:           outpost.Id,
+				Name:         outpost.GetString("name"),
+				Organization: outpost.GetString("organization"),
+				StarSystem:   outpost.GetString("star_system"),
+				Planet:       outpost.GetString("planet"),
+				Moon:         outpost.GetString("moon"),
+				Region:       outpost.GetString("region"),
+				Latitude:     outpost.GetFloat("latitude"),
+				Longitude:    outpost.GetFloat("longitude"),
+				Stock:        items,
+			})
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{"outposts": result})
+	}
+}
+
+// TrackedStock is the stock of a commodity at an outpost, including the tracked commodities never stocked.
+type TrackedStock struct {
+	OutpostStock
+
+	// Tracked is false for a commodity the outpost no longer tracks but still has stock of.
+	Tracked bool `json:"tracked"`
+}
+
+// GetOutpostStock returns a handler listing the stock of the outpost in the path: every commodity
+// it tracks, with an amount of 0 when it has no outpost_commodities row yet, and the commodities
+// it no longer tracks but still has stock of.
+// Users can read the outposts of their organizations, superusers every outpost.
+func GetOutpostStock() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		l := e.App.Logger().WithGroup("routes")
+
+		outpost, err := findMemberOutpost(e, e.Request.PathValue("id"))
+		if err != nil {
+			return e.NotFoundError("Outpost not found.", err)
+		}
+
+		commodities, err := e.App.FindRecordsByFilter("commodities", "archived = false", "name", 0, 0)
+		if err != nil {
+			l.Error("Failed to get commodities", "error", err.Error())
+			return e.InternalServerError("Failed to get the outpost stock.", err)
+		}
+
+		rows, err := e.App.FindAllRecords("outpost_commodities", dbx.HashExp{"outpost": outpost.Id, "archived": false})
+		if err != nil {
+			l.Error("Failed to get outpost stock", "outpost_id", outpost.Id, "error", err.Error())
+			return e.InternalServerError("Failed to get the outpost stock.", err)
+		}
+		amounts := make(map[string]float64, len(rows))
+		for _, row := range rows {
+			amounts[row.GetString("commodity")] = row.GetFloat("amount")
+		}
+
+		stock := []TrackedStock{}
+		for _, commodity := range commodities {
+			amount, stocked := amounts[commodity.Id]
+			tracked := hooks.TracksCommodity(outpost, commodity)
+			if !tracked && (!stocked || amount == 0) {
+				continue
+			}
+
+			stock = append(stock, TrackedStock{
+				OutpostStock: OutpostStock{
+					Commodity: commodity.Id,
+					Name:      commodity.GetString("name"),
+					Code:      commodity.GetString("code"),
+					Amount:    amount,
+				},
+				Tracked: tracked,
+			})
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"outpost":  outpost.Id,
+			"tracking": trackingMode(outpost),
+			"stock":    stock,
+		})
+	}
+}
+
+// trackingMode returns the tracking mode of the outpost, "all" when none is set.
+func trackingMode(outpost *core.Record) string {
+	if mode := outpost.GetString("tracking"); mode != "" {
+		return mode
+	}
+	return hooks.TrackingAll
+}
+
+// findMemberOutpost finds the outpost with the given id, if it belongs to an organization of the
+// authenticated user. Superusers can access every outpost.
+func findMemberOutpost(e *core.RequestEvent, id string) (*core.Record, error) {
+	filter := "id = {:id}"
+	params := dbx.Params{"id": id}
+	if !e.HasSuperuserAuth() {
+		filter += " && organization.members.id ?= {:user}"
+		params["user"] = e.Auth.Id
+	}
+	return e.App.FindFirstRecordByFilter("outposts", filter, params)
+}
@@ -0,0 +1,168 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+const (
+	// defaultHistoryWindow is the time window of the price history when ?from is not given.
+	defaultHistoryWindow = 30 * 24 * time.Hour
+
+	// maxHistoryWindow bounds the time window of a single request.
+	maxHistoryWindow = 366 * 24 * time.Hour
+)
+
+// historyBuckets maps the supported ?interval values to the bucket size of the averages.
+// "raw" (the default) returns every entry as is.
+var historyBuckets = map[string]time.Duration{
+	"raw":    0,
+	"hourly": time.Hour,
+	"daily":  24 * time.Hour,
+}
+
+// PricePoint is a price of a commodity at a point in time, or averaged over a bucket.
+type PricePoint struct {
+	ObservedAt string  `json:"observedAt"`
+	PriceBuy   float64 `json:"priceBuy"`
+	PriceSell  float64 `json:"priceSell"`
+
+	// Samples is the number of history entries averaged into the point.
+	Samples int `json:"samples"`
+}
+
+// GetCommodityPriceHistory returns a handler listing the price history of the commodity in the path.
+//
+// Query parameters:
+//   - from, to: the time window, as RFC 3339 timestamps or dates (default: the last 30 days)
+//   - interval: raw (default), hourly or daily; hourly and daily return the averages per bucket
+func GetCommodityPriceHistory() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		l := e.App.Logger().WithGroup("routes")
+
+		id := e.Request.PathValue("id")
+		query := e.Request.URL.Query()
+
+		commodity, err := e.App.FindRecordById("commodities", id)
+		if err != nil {
+			return e.NotFoundError("Commodity not found.", err)
+		}
+
+		interval := query.Get("interval")
+		if interval == "" {
+			interval = "raw"
+		}
+		bucket, ok := historyBuckets[interval]
+		if !ok {
+			return e.BadRequestError("Unknown interval, expected raw, hourly or daily.", nil)
+		}
+
+		to := time.Now().UTC()
+		if value := query.Get("to"); value != "" {
+			if to, err = parseHistoryTime(value); err != nil {
+				return e.BadRequestError("Invalid to.", err)
+			}
+		}
+		from := to.Add(-defaultHistoryWindow)
+		if value := query.Get("from"); value != "" {
+			if from, err = parseHistoryTime(value); err != nil {
+				return e.BadRequestError("Invalid from.", err)
+			}
+		}
+		if !from.Before(to) || to.Sub(from) > maxHistoryWindow {
+			return e.BadRequestError("The time window must be positive and span at most 366 days.", nil)
+		}
+
+		entries, err := e.App.FindRecordsByFilter(
+			"commodity_price_history",
+			"commodity = {:commodity} && observed_at >= {:from} && observed_at <= {:to}",
+			"observed_at",
+			0,
+			0,
+			dbx.Params{
+				"commodity": commodity.Id,
+				"from":      from.Format(types.DefaultDateLayout),
+				"to":        to.Format(types.DefaultDateLayout),
+			},
+		)
+		if err != nil {
+			l.Error("Failed to get price history", "commodity_id", commodity.Id, "error", err.Error())
+			return e.InternalServerError("Failed to get the price history.", err)
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"commodity": commodity.Id,
+			"from":      from.Format(types.DefaultDateLayout),
+			"to":        to.Format(types.DefaultDateLayout),
+			"interval":  interval,
+			"points":    downsample(priceSamples(entries), bucket),
+		})
+	}
+}
+
+// parseHistoryTime parses an RFC 3339 timestamp or a plain date, in UTC.
+func parseHistoryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// priceSample is a commodity_price_history entry.
+type priceSample struct {
+	observedAt time.Time
+	buy        float64
+	sell       float64
+}
+
+// priceSamples returns the prices of the history entries, in the same order.
+func priceSamples(entries []*core.Record) []priceSample {
+	samples := make([]priceSample, len(entries))
+	for i, entry := range entries {
+		samples[i] = priceSample{
+			observedAt: entry.GetDateTime("observed_at").Time(),
+			buy:        entry.GetFloat("price_buy"),
+			sell:       entry.GetFloat("price_sell"),
+		}
+	}
+	return samples
+}
+
+// downsample averages the samples, sorted by observation time, per bucket of the given size.
+// A zero size returns every sample as its own point.
+func downsample(samples []priceSample, size time.Duration) []PricePoint {
+	points := []PricePoint{}
+
+	var start time.Time
+	for _, sample := range samples {
+		observedAt, buy, sell := sample.observedAt, sample.buy, sample.sell
+
+		if size == 0 {
+			points = append(points, PricePoint{
+				ObservedAt: observedAt.UTC().Format(types.DefaultDateLayout),
+				PriceBuy:   buy,
+				PriceSell:  sell,
+				Samples:    1,
+			})
+			continue
+		}
+
+		bucketStart := observedAt.UTC().Truncate(size)
+		if len(points) == 0 || !bucketStart.Equal(start) {
+			start = bucketStart
+			points = append(points, PricePoint{ObservedAt: start.Format(types.DefaultDateLayout)})
+		}
+
+		// Running average, so the bucket doesn't have to be buffered
+		point := &points[len(points)-1]
+		point.Samples++
+		point.PriceBuy += (buy - point.PriceBuy) / float64(point.Samples)
+		point.PriceSell += (sell - point.PriceSell) / float64(point.Samples)
+	}
+
+	return points
+}
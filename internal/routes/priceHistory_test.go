@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDownsample(t *testing.T) {
+	at := func(day int, hour int, minute int) time.Time {
+		return time.Date(2026, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	samples := []priceSample{
+		{observedAt: at(1, 10, 5), buy: 10, sell: 20},
+		{observedAt: at(1, 10, 45), buy: 20, sell: 30},
+		{observedAt: at(1, 11, 0), buy: 30, sell: 40},
+		{observedAt: at(2, 9, 30).In(time.FixedZone("UTC+2", 2*60*60)), buy: 40, sell: 50},
+	}
+
+	tests := []struct {
+		name    string
+		samples []priceSample
+		size    time.Duration
+		want    []PricePoint
+	}{
+		{
+			name:    "no samples",
+			samples: nil,
+			size:    time.Hour,
+			want:    []PricePoint{},
+		},
+		{
+			name:    "raw",
+			samples: samples,
+			size:    0,
+			want: []PricePoint{
+				{ObservedAt: "2026-03-01 10:05:00.000Z", PriceBuy: 10, PriceSell: 20, Samples: 1},
+				{ObservedAt: "2026-03-01 10:45:00.000Z", PriceBuy: 20, PriceSell: 30, Samples: 1},
+				{ObservedAt: "2026-03-01 11:00:00.000Z", PriceBuy: 30, PriceSell: 40, Samples: 1},
+				{ObservedAt: "2026-03-02 09:30:00.000Z", PriceBuy: 40, PriceSell: 50, Samples: 1},
+			},
+		},
+		{
+			name:    "hourly",
+			samples: samples,
+			size:    time.Hour,
+			want: []PricePoint{
+				{ObservedAt: "2026-03-01 10:00:00.000Z", PriceBuy: 15, PriceSell: 25, Samples: 2},
+				{ObservedAt: "2026-03-01 11:00:00.000Z", PriceBuy: 30, PriceSell: 40, Samples: 1},
+				{ObservedAt: "2026-03-02 09:00:00.000Z", PriceBuy: 40, PriceSell: 50, Samples: 1},
+			},
+		},
+		{
+			name:    "daily",
+			samples: samples,
+			size:    24 * time.Hour,
+			want: []PricePoint{
+				{ObservedAt: "2026-03-01 00:00:00.000Z", PriceBuy: 20, PriceSell: 30, Samples: 3},
+				{ObservedAt: "2026-03-02 00:00:00.000Z", PriceBuy: 40, PriceSell: 50, Samples: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := downsample(tt.samples, tt.size); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("downsample() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
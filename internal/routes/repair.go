@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"net/http"
+
+	"pulsepoint/internal/errs"
+	"pulsepoint/internal/hooks"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// RepairOutpostCommodities returns a handler creating the missing (outpost, commodity) rows
+// of the outposts tracking all commodities, see hooks.RepairOutpostCommodities.
+// With ?dryRun=true it only reports how many rows are missing.
+func RepairOutpostCommodities() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		l := e.App.Logger().WithGroup("routes")
+
+		dryRun := e.Request.URL.Query().Get("dryRun") == "true"
+
+		missing, err := hooks.RepairOutpostCommodities(e.App, dryRun)
+		if err != nil {
+			l.Error("Failed to repair outpost commodities", "error", err.Error())
+			return e.Error(errs.HTTPStatus(err), "Failed to repair the outpost commodities.", map[string]string{"code": errs.Code(err)})
+		}
+
+		created := missing
+		if dryRun {
+			created = 0
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"success": true,
+			"dryRun":  dryRun,
+			"missing": missing,
+			"created": created,
+		})
+	}
+}
@@ -0,0 +1,377 @@
+package routes
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+const (
+	// defaultRouteLimit is the number of routes returned when ?limit is not given.
+	defaultRouteLimit = 20
+
+	// maxRouteLimit bounds the number of routes of a single request.
+	maxRouteLimit = 100
+)
+
+// padSizes are the landing pad sizes, from the smallest to the largest.
+var padSizes = []string{"XS", "S", "M", "L", "XL"}
+
+// routeRankings maps the supported ?sort values to the value the routes are ranked by.
+var routeRankings = map[string]func(r TradeRoute) float64{
+	"profit":       func(r TradeRoute) float64 { return r.Profit },
+	"profitPerScu": func(r TradeRoute) float64 { return r.ProfitPerScu },
+	"margin":       func(r TradeRoute) float64 { return r.Margin },
+}
+
+// RouteCommodity is the commodity traded on a route.
+type RouteCommodity struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Code      string `json:"code"`
+	IsIllegal bool   `json:"isIllegal"`
+}
+
+// RouteTerminal is the terminal a route buys at or sells to.
+type RouteTerminal struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	StarSystem   string `json:"starSystem,omitempty"`
+	SpaceStation string `json:"spaceStation,omitempty"`
+	ReportedAt   string `json:"reportedAt,omitempty"`
+}
+
+// TradeRoute is a commodity bought at one terminal and sold at another.
+type TradeRoute struct {
+	Commodity RouteCommodity `json:"commodity"`
+	From      RouteTerminal  `json:"from"`
+	To        RouteTerminal  `json:"to"`
+	PriceBuy  float64        `json:"priceBuy"`
+	PriceSell float64        `json:"priceSell"`
+
+	// Scu is the quantity to trade: the cargo capacity, bounded by the capital and the reported stock and demand.
+	Scu          int     `json:"scu"`
+	Investment   float64 `json:"investment"`
+	Profit       float64 `json:"profit"`
+	ProfitPerScu float64 `json:"profitPerScu"`
+
+	// Margin is the profit in percent of the investment.
+	Margin float64 `json:"margin"`
+}
+
+// routeTerminal is a terminal a route can start or end at.
+type routeTerminal struct {
+	record *core.Record
+	ref    RouteTerminal
+
+	// padTypes are the pad types of the space station of the terminal, e.g. "S,M,L",
+	// empty outside of a space station or when they aren't reported.
+	padTypes string
+}
+
+// routeQuote is one side of a terminal price: what a commodity is bought or sold at
+// and the SCU available for it, 0 when UEX doesn't know.
+type routeQuote struct {
+	price float64
+	scu   int
+}
+
+// buyQuote returns what a terminal sells the commodity of the price at, and its stock.
+func buyQuote(price *core.Record) routeQuote {
+	return routeQuote{price: price.GetFloat("price_buy"), scu: int(price.GetFloat("scu_buy"))}
+}
+
+// sellQuote returns what a terminal buys the commodity of the price at, and its demand.
+func sellQuote(price *core.Record) routeQuote {
+	return routeQuote{price: price.GetFloat("price_sell"), scu: int(price.GetFloat("scu_sell"))}
+}
+
+// FindTradeRoutes returns a handler listing the most profitable buy→sell routes,
+// based on the commodity prices per terminal synced by tasks.UpdateTerminals.
+//
+// Query parameters:
+//   - cargo: the cargo capacity of the ship in SCU (required)
+//   - capital: the aUEC available to buy the cargo (required)
+//   - startSystem, startStation: only routes buying in this star system / at this space station
+//   - legalOnly: true to skip illegal commodities
+//   - padSize: XS, S, M, L or XL, only routes whose space stations have a pad at least that large.
+//     Terminals outside of a space station, or at a station without reported pad types, are kept.
+//   - sort: profit (default), profitPerScu or margin
+//   - limit: the number of routes to return (default 20, at most 100)
+func FindTradeRoutes() func(e *core.RequestEvent) error {
+	return func(e *core.RequestEvent) error {
+		l := e.App.Logger().WithGroup("routes")
+
+		query := e.Request.URL.Query()
+
+		cargo, err := strconv.Atoi(query.Get("cargo"))
+		if err != nil || cargo <= 0 {
+			return e.BadRequestError("cargo must be a positive number of SCU.", err)
+		}
+
+		capital, err := strconv.ParseFloat(query.Get("capital"), 64)
+		if err != nil || capital <= 0 || math.IsInf(capital, 0) {
+			return e.BadRequestError("capital must be a positive amount of aUEC.", err)
+		}
+
+		sortBy := query.Get("sort")
+		if sortBy == "" {
+			sortBy = "profit"
+		}
+		rank, ok := routeRankings[sortBy]
+		if !ok {
+			return e.BadRequestError("Unknown sort, expected profit, profitPerScu or margin.", nil)
+		}
+
+		limit := defaultRouteLimit
+		if value := query.Get("limit"); value != "" {
+			if limit, err = strconv.Atoi(value); err != nil || limit <= 0 || limit > maxRouteLimit {
+				return e.BadRequestError("limit must be between 1 and 100.", err)
+			}
+		}
+
+		minPad := -1
+		if value := query.Get("padSize"); value != "" {
+			if minPad = padSizeIndex(value); minPad < 0 {
+				return e.BadRequestError("Unknown padSize, expected XS, S, M, L or XL.", nil)
+			}
+		}
+
+		startSystem := query.Get("startSystem")
+		if startSystem != "" {
+			if _, err := e.App.FindRecordById("star_systems", startSystem); err != nil {
+				return e.BadRequestError("Unknown startSystem.", err)
+			}
+		}
+		startStation := query.Get("startStation")
+		if startStation != "" {
+			if _, err := e.App.FindRecordById("space_stations", startStation); err != nil {
+				return e.BadRequestError("Unknown startStation.", err)
+			}
+		}
+
+		legalOnly := query.Get("legalOnly") == "true"
+
+		terminals, err := loadRouteTerminals(e.App)
+		if err != nil {
+			l.Error("Failed to load terminals", "error", err.Error())
+			return e.InternalServerError("Failed to find routes.", err)
+		}
+
+		commodities, err := e.App.FindRecordsByFilter("commodities", "archived = false", "", 0, 0)
+		if err != nil {
+			l.Error("Failed to load commodities", "error", err.Error())
+			return e.InternalServerError("Failed to find routes.", err)
+		}
+		commoditiesById := make(map[string]*core.Record, len(commodities))
+		for _, commodity := range commodities {
+			if legalOnly && commodity.GetBool("is_illegal") {
+				continue
+			}
+			commoditiesById[commodity.Id] = commodity
+		}
+
+		prices, err := e.App.FindRecordsByFilter("terminal_commodity_prices", "archived = false", "", 0, 0)
+		if err != nil {
+			l.Error("Failed to load terminal prices", "error", err.Error())
+			return e.InternalServerError("Failed to find routes.", err)
+		}
+
+		// The prices of every commodity, split into where it can be bought and where it can be sold
+		buys := map[string][]*core.Record{}
+		sells := map[string][]*core.Record{}
+		for _, price := range prices {
+			commodityId := price.GetString("commodity")
+			if commoditiesById[commodityId] == nil {
+				continue
+			}
+
+			terminal := terminals[price.GetString("terminal")]
+			if terminal == nil || !terminal.fitsPad(minPad) {
+				continue
+			}
+
+			if price.GetFloat("price_buy") > 0 &&
+				(startSystem == "" || terminal.record.GetString("star_system") == startSystem) &&
+				(startStation == "" || terminal.record.GetString("space_station") == startStation) {
+				buys[commodityId] = append(buys[commodityId], price)
+			}
+			if price.GetFloat("price_sell") > 0 {
+				sells[commodityId] = append(sells[commodityId], price)
+			}
+		}
+
+		routes := []TradeRoute{}
+		for commodityId, commodityBuys := range buys {
+			commodity := commoditiesById[commodityId]
+
+			for _, buy := range commodityBuys {
+				for _, sell := range sells[commodityId] {
+					if buy.GetString("terminal") == sell.GetString("terminal") {
+						continue
+					}
+
+					route, ok := tradeRoute(buyQuote(buy), sellQuote(sell), cargo, capital)
+					if !ok {
+						continue
+					}
+
+					route.Commodity = RouteCommodity{
+						ID:        commodity.Id,
+						Name:      commodity.GetString("name"),
+						Code:      commodity.GetString("code"),
+						IsIllegal: commodity.GetBool("is_illegal"),
+					}
+					route.From = terminals[buy.GetString("terminal")].at(buy)
+					route.To = terminals[sell.GetString("terminal")].at(sell)
+					routes = append(routes, route)
+				}
+			}
+		}
+
+		// Best first, ties broken by the profit and then by name so the order is stable between requests
+		sort.Slice(routes, func(i, j int) bool {
+			a, b := routes[i], routes[j]
+			if rank(a) != rank(b) {
+				return rank(a) > rank(b)
+			}
+			if a.Profit != b.Profit {
+				return a.Profit > b.Profit
+			}
+			return a.Commodity.Name+a.From.Name+a.To.Name < b.Commodity.Name+b.From.Name+b.To.Name
+		})
+
+		total := len(routes)
+		if len(routes) > limit {
+			routes = routes[:limit]
+		}
+
+		return e.JSON(http.StatusOK, map[string]any{
+			"sort":   sortBy,
+			"total":  total,
+			"routes": routes,
+		})
+	}
+}
+
+// tradeRoute computes the route buying at buy and selling at sell, both quotes of the
+// same commodity. It returns false when the route makes no profit or nothing can be traded.
+func tradeRoute(buy routeQuote, sell routeQuote, cargo int, capital float64) (TradeRoute, bool) {
+	priceBuy := buy.price
+	priceSell := sell.price
+
+	profitPerScu := priceSell - priceBuy
+	if profitPerScu <= 0 {
+		return TradeRoute{}, false
+	}
+
+	scu := cargo
+	if affordable := int(capital / priceBuy); affordable < scu {
+		scu = affordable
+	}
+	// A stock or a demand of 0 means UEX doesn't know it, not that there is none
+	if buy.scu > 0 && buy.scu < scu {
+		scu = buy.scu
+	}
+	if sell.scu > 0 && sell.scu < scu {
+		scu = sell.scu
+	}
+	if scu <= 0 {
+		return TradeRoute{}, false
+	}
+
+	return TradeRoute{
+		PriceBuy:     priceBuy,
+		PriceSell:    priceSell,
+		Scu:          scu,
+		Investment:   priceBuy * float64(scu),
+		Profit:       profitPerScu * float64(scu),
+		ProfitPerScu: profitPerScu,
+		Margin:       math.Round(profitPerScu/priceBuy*10000) / 100,
+	}, true
+}
+
+// loadRouteTerminals loads the active terminals, with the names of their star system and space station.
+func loadRouteTerminals(app core.App) (map[string]*routeTerminal, error) {
+	records, err := app.FindRecordsByFilter("terminals", "archived = false", "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	starSystems, err := app.FindAllRecords("star_systems")
+	if err != nil {
+		return nil, err
+	}
+	starSystemNames := make(map[string]string, len(starSystems))
+	for _, starSystem := range starSystems {
+		starSystemNames[starSystem.Id] = starSystem.GetString("name")
+	}
+
+	stations, err := app.FindAllRecords("space_stations")
+	if err != nil {
+		return nil, err
+	}
+	stationsById := make(map[string]*core.Record, len(stations))
+	for _, station := range stations {
+		stationsById[station.Id] = station
+	}
+
+	terminals := make(map[string]*routeTerminal, len(records))
+	for _, record := range records {
+		terminal := &routeTerminal{
+			record: record,
+			ref: RouteTerminal{
+				ID:         record.Id,
+				Name:       record.GetString("name"),
+				StarSystem: starSystemNames[record.GetString("star_system")],
+			},
+		}
+		if station := stationsById[record.GetString("space_station")]; station != nil {
+			terminal.ref.SpaceStation = station.GetString("name")
+			terminal.padTypes = station.GetString("pad_types")
+		}
+		terminals[record.Id] = terminal
+	}
+
+	return terminals, nil
+}
+
+// at returns the terminal as reported in a route, with the report date of the given price.
+func (t *routeTerminal) at(price *core.Record) RouteTerminal {
+	ref := t.ref
+	if reportedAt := price.GetDateTime("reported_at"); !reportedAt.IsZero() {
+		ref.ReportedAt = reportedAt.String()
+	}
+	return ref
+}
+
+// fitsPad reports whether a ship needing a pad of the given size (an index of padSizes,
+// -1 for any) can dock at the terminal. Terminals outside of a space station, or at
+// a station without reported pad types, are assumed to fit.
+func (t *routeTerminal) fitsPad(minPad int) bool {
+	if minPad < 0 || strings.TrimSpace(t.padTypes) == "" {
+		return true
+	}
+
+	for _, padType := range strings.Split(t.padTypes, ",") {
+		if padSizeIndex(padType) >= minPad {
+			return true
+		}
+	}
+	return false
+}
+
+// padSizeIndex returns the index of the pad size in padSizes, -1 when it is unknown.
+func padSizeIndex(size string) int {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	for i, padSize := range padSizes {
+		if padSize == size {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,156 @@
+package routes
+
+import "testing"
+
+func TestTradeRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		buy     routeQuote
+		sell    routeQuote
+		cargo   int
+		capital float64
+		want    TradeRoute
+		wantOk  bool
+	}{
+		{
+			name:    "cargo bound",
+			buy:     routeQuote{price: 100},
+			sell:    routeQuote{price: 150},
+			cargo:   32,
+			capital: 1_000_000,
+			want:    TradeRoute{PriceBuy: 100, PriceSell: 150, Scu: 32, Investment: 3200, Profit: 1600, ProfitPerScu: 50, Margin: 50},
+			wantOk:  true,
+		},
+		{
+			name:    "capital bound",
+			buy:     routeQuote{price: 100, scu: 500},
+			sell:    routeQuote{price: 150, scu: 500},
+			cargo:   96,
+			capital: 2550,
+			want:    TradeRoute{PriceBuy: 100, PriceSell: 150, Scu: 25, Investment: 2500, Profit: 1250, ProfitPerScu: 50, Margin: 50},
+			wantOk:  true,
+		},
+		{
+			name:    "stock bound",
+			buy:     routeQuote{price: 100, scu: 10},
+			sell:    routeQuote{price: 150, scu: 500},
+			cargo:   96,
+			capital: 1_000_000,
+			want:    TradeRoute{PriceBuy: 100, PriceSell: 150, Scu: 10, Investment: 1000, Profit: 500, ProfitPerScu: 50, Margin: 50},
+			wantOk:  true,
+		},
+		{
+			name:    "demand bound",
+			buy:     routeQuote{price: 100, scu: 500},
+			sell:    routeQuote{price: 150, scu: 7},
+			cargo:   96,
+			capital: 1_000_000,
+			want:    TradeRoute{PriceBuy: 100, PriceSell: 150, Scu: 7, Investment: 700, Profit: 350, ProfitPerScu: 50, Margin: 50},
+			wantOk:  true,
+		},
+		{
+			name:    "unknown stock and demand",
+			buy:     routeQuote{price: 100, scu: 0},
+			sell:    routeQuote{price: 150, scu: 0},
+			cargo:   12,
+			capital: 1_000_000,
+			want:    TradeRoute{PriceBuy: 100, PriceSell: 150, Scu: 12, Investment: 1200, Profit: 600, ProfitPerScu: 50, Margin: 50},
+			wantOk:  true,
+		},
+		{
+			name:    "margin rounded to two decimals",
+			buy:     routeQuote{price: 3},
+			sell:    routeQuote{price: 4},
+			cargo:   3,
+			capital: 1_000_000,
+			want:    TradeRoute{PriceBuy: 3, PriceSell: 4, Scu: 3, Investment: 9, Profit: 3, ProfitPerScu: 1, Margin: 33.33},
+			wantOk:  true,
+		},
+		{
+			name:    "no profit",
+			buy:     routeQuote{price: 150},
+			sell:    routeQuote{price: 150},
+			cargo:   96,
+			capital: 1_000_000,
+		},
+		{
+			name:    "loss",
+			buy:     routeQuote{price: 150},
+			sell:    routeQuote{price: 100},
+			cargo:   96,
+			capital: 1_000_000,
+		},
+		{
+			name:    "not a single SCU affordable",
+			buy:     routeQuote{price: 100},
+			sell:    routeQuote{price: 150},
+			cargo:   96,
+			capital: 99,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tradeRoute(tt.buy, tt.sell, tt.cargo, tt.capital)
+			if ok != tt.wantOk {
+				t.Fatalf("tradeRoute() ok = %t, want %t", ok, tt.wantOk)
+			}
+			if got != tt.want {
+				t.Errorf("tradeRoute() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFitsPad(t *testing.T) {
+	tests := []struct {
+		name     string
+		padTypes string
+		minPad   string
+		want     bool
+	}{
+		{"any pad", "XS,S", "", true},
+		{"large enough pad", "S,M,L", "L", true},
+		{"larger pad", "S,XL", "M", true},
+		{"pads too small", "XS,S,M", "L", false},
+		{"spaces and lower case", " xs , l ", "L", true},
+		{"unknown pad types only", "huge", "XS", false},
+		{"outside of a space station", "", "XL", true},
+		{"pad types not reported", "  ", "XL", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			minPad := -1
+			if tt.minPad != "" {
+				minPad = padSizeIndex(tt.minPad)
+			}
+
+			terminal := &routeTerminal{padTypes: tt.padTypes}
+			if got := terminal.fitsPad(minPad); got != tt.want {
+				t.Errorf("fitsPad(%q) with pads %q = %t, want %t", tt.minPad, tt.padTypes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPadSizeIndex(t *testing.T) {
+	tests := []struct {
+		size string
+		want int
+	}{
+		{"XS", 0},
+		{"m", 2},
+		{" XL ", 4},
+		{"XXL", -1},
+		{"", -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			if got := padSizeIndex(tt.size); got != tt.want {
+				t.Errorf("padSizeIndex(%q) = %d, want %d", tt.size, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package tasks
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// archiveMissing archives the active records of the collection whose key field
+// (e.g. "uex_id") is not in present, i.e. the records that disappeared from UEX.
+// Archived records stay in the database, so existing relations keep working,
+// but they are hidden from the default API listings. It returns the number of archived records.
+// Dry runs report every archived record into the diff of the run.
+//
+// Nothing is archived when present is empty: an empty upstream list is more
+// likely a glitch than every record being gone.
+func archiveMissing(txApp core.App, run *SyncRun, collection string, key string, present map[string]bool) (int, error) {
+	if len(present) == 0 {
+		l := txApp.Logger().WithGroup("archive")
+		l.Warn("Nothing listed upstream, skipping archiving", "collection", collection)
+		return 0, nil
+	}
+
+	records, err := txApp.FindRecordsByFilter(collection, "archived = false", "", 0, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for _, record := range records {
+		if present[record.GetString(key)] {
+			continue
+		}
+
+		if run.diff != nil {
+			run.diff.addArchive(collection, record.GetString(key), record.GetString("name"), "no longer listed by UEX")
+		}
+
+		record.Set("archived", true)
+		record.Set("archived_at", types.NowDateTime())
+		changes := changedFields(record)
+		if err := txApp.Save(record); err != nil {
+			return archived, err
+		}
+		archived++
+
+		event := &RecordChangeEvent{
+			App:        txApp,
+			Record:     record,
+			Collection: collection,
+			RecordID:   record.Id,
+			Key:        record.GetString(key),
+			Changes:    changes,
+		}
+		if err := onRecordSave.Trigger(event); err != nil {
+			return archived, err
+		}
+		run.trackChange(event)
+	}
+
+	return archived, nil
+}
+
+// restoreArchived clears the archived flag of a record that reappeared upstream.
+// The record still has to be saved by the caller. It returns whether the record was archived.
+func restoreArchived(record *core.Record) bool {
+	if !record.GetBool("archived") {
+		return false
+	}
+
+	record.Set("archived", false)
+	record.Set("archived_at", "")
+	return true
+}
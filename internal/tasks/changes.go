@@ -0,0 +1,114 @@
+package tasks
+
+import (
+	"errors"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/hook"
+)
+
+// RecordChangeEvent is published for every record a sync created or changed:
+// to OnRecordSave right after the record is written, inside the sync transaction,
+// then to OnRecordChange once that transaction is committed. Dry runs publish nothing to OnRecordChange.
+type RecordChangeEvent struct {
+	hook.Event
+
+	// App is the transaction the record was written in for OnRecordSave,
+	// the application for OnRecordChange.
+	App core.App
+
+	// Record is the record as saved.
+	Record *core.Record
+
+	// Collection is the name of the collection of the record, e.g. "commodities".
+	Collection string
+
+	// RecordID is the PocketBase id of the record.
+	RecordID string
+
+	// Key is the upstream identity of the record, its UEX id.
+	Key string
+
+	// Created is true for a new record, Changes then holds all of its fields.
+	Created bool
+
+	// Changes holds the changed fields with their old and new value.
+	Changes map[string]FieldChange
+}
+
+// onRecordSave and onRecordChange are shared by every task, subscribers don't have to know which one wrote a record.
+var (
+	onRecordSave   = &hook.Hook[*RecordChangeEvent]{}
+	onRecordChange = &hook.Hook[*RecordChangeEvent]{}
+)
+
+// OnRecordSave returns the hook triggered for every record a sync created or changed,
+// inside the transaction that wrote it, dry runs included. Handlers must call e.Next()
+// to let the next handler run and write through e.App. An error returned by a handler
+// fails the transaction: the record and whatever the handlers wrote are rolled back together.
+func OnRecordSave() *hook.Hook[*RecordChangeEvent] {
+	return onRecordSave
+}
+
+// OnRecordChange returns the hook triggered for every record a sync created or changed.
+// Handlers must call e.Next() to let the next handler run. An error returned by a
+// handler is logged, it doesn't fail the sync: the record is already committed.
+func OnRecordChange() *hook.Hook[*RecordChangeEvent] {
+	return onRecordChange
+}
+
+// recordChanges returns the fields a save of the record would write:
+// every field for a new record, the changed ones for an existing record.
+func recordChanges(record *core.Record) map[string]FieldChange {
+	if !record.IsNew() {
+		return changedFields(record)
+	}
+
+	changes := map[string]FieldChange{}
+	for name, value := range record.FieldsData() {
+		if !diffIgnoredFields[name] {
+			changes[name] = FieldChange{New: value}
+		}
+	}
+	return changes
+}
+
+// syncTransaction runs fn in a transaction and publishes the changes it tracked
+// once the transaction is committed. The changes of a failed transaction are dropped.
+//
+// The transaction of a dry run is always rolled back, so the SQLite writer is only
+// held while the task writes, never while it waits for UEX. Each stage of a dry run
+// is therefore compared against the committed data, not against what the previous
+// stages would have written (e.g. the planets of a new star system are not listed).
+func syncTransaction(app core.App, run *SyncRun, fn func(txApp core.App) error) error {
+	err := app.RunInTransaction(func(txApp core.App) error {
+		if err := fn(txApp); err != nil {
+			return err
+		}
+		if run.DryRun {
+			return errDryRunRollback
+		}
+		return nil
+	})
+
+	changes := run.takeChanges()
+	if errors.Is(err, errDryRunRollback) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	l := app.Logger().WithGroup("changes")
+	for _, event := range changes {
+		event.App = app
+		if err := onRecordChange.Trigger(event); err != nil {
+			l.Error("Record change handler failed",
+				"collection", event.Collection,
+				"record_id", event.RecordID,
+				"error", err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package tasks
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// diffIgnoredFields are managed by PocketBase and never part of a diff.
+var diffIgnoredFields = map[string]bool{"id": true, "created": true, "updated": true}
+
+// FieldChange is the stored and the incoming value of a changed field.
+type FieldChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+// DiffEntry describes what a sync would do to a single record.
+type DiffEntry struct {
+	// Key is the upstream identity of the record, its UEX id.
+	Key string `json:"key"`
+
+	// Name is the name of the record, for humans reading the diff.
+	Name string `json:"name,omitempty"`
+
+	// Fields holds the values of a record that would be created.
+	Fields map[string]any `json:"fields,omitempty"`
+
+	// Changes holds the fields of an existing record that would change.
+	Changes map[string]FieldChange `json:"changes,omitempty"`
+
+	// Reason explains why a record is skipped or archived.
+	Reason string `json:"reason,omitempty"`
+}
+
+// CollectionDiff groups the entries of one collection.
+type CollectionDiff struct {
+	New       []DiffEntry `json:"new"`
+	Changed   []DiffEntry `json:"changed"`
+	Archive   []DiffEntry `json:"archive"`
+	Skipped   []DiffEntry `json:"skipped"`
+	Unchanged int         `json:"unchanged"`
+}
+
+// Diff is the structured report of a dry run: what the sync would write,
+// per collection, without anything being committed.
+type Diff struct {
+	mu          sync.Mutex
+	collections map[string]*CollectionDiff
+}
+
+// NewDiff creates an empty Diff.
+func NewDiff() *Diff {
+	return &Diff{collections: map[string]*CollectionDiff{}}
+}
+
+// Collections returns a copy of the entries of every collection touched by the sync, sorted by key.
+// The sync may still be adding entries, the copy is safe to read meanwhile.
+func (d *Diff) Collections() map[string]*CollectionDiff {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	collections := make(map[string]*CollectionDiff, len(d.collections))
+	for name, c := range d.collections {
+		collections[name] = &CollectionDiff{
+			New:       sortedEntries(c.New),
+			Changed:   sortedEntries(c.Changed),
+			Archive:   sortedEntries(c.Archive),
+			Skipped:   sortedEntries(c.Skipped),
+			Unchanged: c.Unchanged,
+		}
+	}
+
+	return collections
+}
+
+// sortedEntries returns a copy of entries sorted by key.
+// The Fields and Changes maps are shared, they are never modified once the entry is added.
+func sortedEntries(entries []DiffEntry) []DiffEntry {
+	sorted := make([]DiffEntry, len(entries))
+	copy(sorted, entries)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return sorted
+}
+
+// collection returns the diff of the named collection, creating it on first use.
+// The caller must hold d.mu.
+func (d *Diff) collection(name string) *CollectionDiff {
+	c, ok := d.collections[name]
+	if !ok {
+		c = &CollectionDiff{New: []DiffEntry{}, Changed: []DiffEntry{}, Archive: []DiffEntry{}, Skipped: []DiffEntry{}}
+		d.collections[name] = c
+	}
+	return c
+}
+
+// addSave records a record about to be saved: a new one with all its values,
+// an existing one with its changed fields only.
+func (d *Diff) addSave(collection string, key string, record *core.Record) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := d.collection(collection)
+
+	if record.IsNew() {
+		fields := map[string]any{}
+		for name, value := range record.FieldsData() {
+			if !diffIgnoredFields[name] {
+				fields[name] = value
+			}
+		}
+		c.New = append(c.New, DiffEntry{Key: key, Name: record.GetString("name"), Fields: fields})
+		return
+	}
+
+	changes := changedFields(record)
+	if len(changes) == 0 {
+		c.Unchanged++
+		return
+	}
+	c.Changed = append(c.Changed, DiffEntry{Key: key, Name: record.GetString("name"), Changes: changes})
+}
+
+// addSkip records an upstream record that is ignored by the sync.
+func (d *Diff) addSkip(collection string, key string, name string, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := d.collection(collection)
+	c.Skipped = append(c.Skipped, DiffEntry{Key: key, Name: name, Reason: reason})
+}
+
+// addArchive records a stored record that would be archived.
+func (d *Diff) addArchive(collection string, key string, name string, reason string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	c := d.collection(collection)
+	c.Archive = append(c.Archive, DiffEntry{Key: key, Name: name, Reason: reason})
+}
+
+// changedFields compares the current values of an existing record with the stored ones.
+// Values are compared by their string form, Set already normalized them to the field types.
+func changedFields(record *core.Record) map[string]FieldChange {
+	original := record.Original().FieldsData()
+
+	changes := map[string]FieldChange{}
+	for name, value := range record.FieldsData() {
+		if diffIgnoredFields[name] {
+			continue
+		}
+
+		old := original[name]
+		if fmt.Sprint(old) != fmt.Sprint(value) {
+			changes[name] = FieldChange{Old: old, New: value}
+		}
+	}
+
+	return changes
+}
+
+// saveRecord saves a synced record, unless it is an existing record none of whose
+// fields changed: rewriting it would only bump its updated timestamp.
+// The change is published to OnRecordSave right away, and to OnRecordChange once the
+// transaction is committed (see syncTransaction). For a dry run the change is reported into the diff of the run first, the
+// transaction is rolled back afterwards. It returns whether the record was saved.
+func saveRecord(txApp core.App, run *SyncRun, collection string, key string, record *core.Record) (bool, error) {
+	if run.diff != nil {
+		run.diff.addSave(collection, key, record)
+	}
+
+	created := record.IsNew()
+	changes := recordChanges(record)
+	if !created && len(changes) == 0 {
+		return false, nil
+	}
+
+	if err := txApp.Save(record); err != nil {
+		return false, err
+	}
+
+	event := &RecordChangeEvent{
+		App:        txApp,
+		Record:     record,
+		Collection: collection,
+		RecordID:   record.Id,
+		Key:        key,
+		Created:    created,
+		Changes:    changes,
+	}
+	if err := onRecordSave.Trigger(event); err != nil {
+		return false, err
+	}
+	run.trackChange(event)
+
+	return true, nil
+}
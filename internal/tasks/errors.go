@@ -0,0 +1,66 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"pulsepoint/internal/errs"
+	"pulsepoint/internal/uex"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+var (
+	// ErrRunnerStopped is returned when a task is started after the application began to terminate.
+	ErrRunnerStopped = errors.New("tasks runner is stopped")
+
+	// ErrAlreadyRunning is returned when a task is triggered while it is still running.
+	ErrAlreadyRunning = errors.New("task is already running")
+
+	// errDryRunRollback aborts the transactions of a dry run once their writes are done.
+	errDryRunRollback = errors.New("dry run, rolling back")
+)
+
+// upstreamError wraps an error returned by the UEX client with the matching errs kind.
+// Cancellations are returned as is, they are not the upstream's fault.
+func upstreamError(action string, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%s: %w", action, err)
+	case uex.IsTemporary(err):
+		return fmt.Errorf("%s: %w: %w", action, errs.ErrUpstreamUnavailable, err)
+	default:
+		return fmt.Errorf("%s: %w: %w", action, errs.ErrUpstreamResponse, err)
+	}
+}
+
+// findCollection looks up a collection by name and wraps a failure with errs.ErrCollectionMissing.
+func findCollection(app core.App, name string) (*core.Collection, error) {
+	collection, err := app.FindCollectionByNameOrId(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", errs.ErrCollectionMissing, name, err)
+	}
+	return collection, nil
+}
+
+// reportInvalid logs the rows of a UEX response that could not be decoded and
+// counts them as failed. The sync carries on with the valid rows.
+func reportInvalid(app core.App, run *SyncRun, collection string, invalid []*uex.RecordError) {
+	if len(invalid) == 0 {
+		return
+	}
+
+	l := app.Logger().WithGroup("tasks")
+
+	for _, recordErr := range invalid {
+		l.Warn("Skipping invalid UEX record",
+			"collection", collection,
+			"uex_id", int64(recordErr.ID),
+			"name", recordErr.Name,
+			"error", recordErr.Err.Error())
+		run.SkipRecord(collection, uexKey(recordErr.ID), recordErr.Name, "invalid UEX record: "+recordErr.Err.Error())
+	}
+
+	run.Add(collection, CollectionStats{Failed: len(invalid)})
+}
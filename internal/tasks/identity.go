@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"pulsepoint/internal/uex"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// recordIndex holds the records of a synced collection in memory, so a batch
+// resolves the existing records and their relations without a query per row.
+//
+// Records are keyed by their UEX id. Records synced before the UEX id was stored
+// have uex_id = 0, they are matched once by their legacy key (code, or name for
+// space stations) instead, the caller then sets their uex_id so later syncs find
+// them by id, even after a rename upstream.
+type recordIndex struct {
+	byUexID  map[uex.ID]*core.Record
+	byLegacy map[string]*core.Record
+}
+
+// loadIndex loads every record of the collection, archived ones included so they can be restored.
+// legacyKey may be empty when the records have no legacy key to match.
+func loadIndex(txApp core.App, collection string, legacyKey string) (*recordIndex, error) {
+	records, err := txApp.FindAllRecords(collection)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &recordIndex{
+		byUexID:  make(map[uex.ID]*core.Record, len(records)),
+		byLegacy: map[string]*core.Record{},
+	}
+
+	for _, record := range records {
+		// 0 is the placeholder of the records not backfilled yet, never a real UEX id
+		if uexID := uex.ID(record.GetInt("uex_id")); uexID != 0 {
+			index.byUexID[uexID] = record
+		} else if legacyKey != "" {
+			index.byLegacy[record.GetString(legacyKey)] = record
+		}
+	}
+
+	return index, nil
+}
+
+// find returns the record synced from the given UEX id, or the not yet backfilled
+// record whose legacy key is legacyValue. It returns nil when there is none.
+func (index *recordIndex) find(uexID uex.ID, legacyValue string) *core.Record {
+	if record := index.get(uexID); record != nil {
+		return record
+	}
+
+	record, ok := index.byLegacy[legacyValue]
+	if !ok || legacyValue == "" {
+		return nil
+	}
+
+	// From now on the record is known by its UEX id
+	delete(index.byLegacy, legacyValue)
+	index.put(uexID, record)
+
+	return record
+}
+
+// get returns the record synced from the given UEX id, e.g. to resolve a relation, or nil.
+func (index *recordIndex) get(uexID uex.ID) *core.Record {
+	if uexID == 0 {
+		return nil
+	}
+	return index.byUexID[uexID]
+}
+
+// put indexes a newly created record, so the following rows of the batch find it.
+func (index *recordIndex) put(uexID uex.ID, record *core.Record) {
+	if uexID != 0 {
+		index.byUexID[uexID] = record
+	}
+}
+
+// relationID returns the id of the record to store in a relation field, "" for no record.
+func relationID(record *core.Record) string {
+	if record == nil {
+		return ""
+	}
+	return record.Id
+}
+
+// uexKey is the key of a synced record in the present maps and the diff of a run.
+func uexKey(uexID uex.ID) string {
+	return uexID.String()
+}
@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// driftTolerance absorbs the rounding of the float sums of the ledger.
+const driftTolerance = 1e-6
+
+// ledgerTotal is the sum of the changes of an outpost commodity.
+type ledgerTotal struct {
+	OutpostCommodity string  `db:"outpost_commodity"`
+	Total            float64 `db:"total"`
+}
+
+// ReconcileInventory recomputes the amount of every active outpost commodity from the
+// outpost_commodity_changes ledger, the source of truth of the stock, and corrects the amounts
+// that drifted from it, e.g. after a superuser edited an amount directly.
+// As a dry run it only reports the drift: the diff lists the drifted amounts with their ledger value,
+// keyed by outpost commodity id, and is stored with the run.
+// Corrections are saved without a ledger entry, they bring the amount back in line with the ledger.
+//
+// The inventory is scanned outside of any transaction, so stock changes are not blocked meanwhile.
+// Only the drifted rows are checked again, and corrected, in the transaction.
+func ReconcileInventory(ctx context.Context, app core.App, run *SyncRun) error {
+	l := app.Logger().WithGroup("reconcileInventory")
+
+	l.Info("Inventory reconciliation has started")
+	run.SetStage("outpost_commodities")
+
+	var stats CollectionStats
+
+	ledger, err := ledgerTotals(app, nil)
+	if err != nil {
+		l.Error("Failed to sum the ledger", "error", err.Error())
+		return fmt.Errorf("summing the ledger: %w", err)
+	}
+
+	outpostCommodities, err := app.FindAllRecords("outpost_commodities", dbx.HashExp{"archived": false})
+	if err != nil {
+		l.Error("Failed to load outpost commodities", "error", err.Error())
+		return fmt.Errorf("loading outpost commodities: %w", err)
+	}
+
+	var drifted []string
+	for _, outpostCommodity := range outpostCommodities {
+		if math.Abs(outpostCommodity.GetFloat("amount")-ledger[outpostCommodity.Id]) <= driftTolerance {
+			stats.Unchanged++
+			continue
+		}
+		drifted = append(drifted, outpostCommodity.Id)
+	}
+
+	if len(drifted) > 0 {
+		err = syncTransaction(app, run, func(txPb core.App) error {
+			// The stock may have changed since the scan, compare the committed values again
+			ledger, err := ledgerTotals(txPb, drifted)
+			if err != nil {
+				l.Error("Failed to sum the ledger", "error", err.Error())
+				return fmt.Errorf("summing the ledger: %w", err)
+			}
+
+			outpostCommodities, err := txPb.FindRecordsByIds("outpost_commodities", drifted)
+			if err != nil {
+				l.Error("Failed to load outpost commodities", "error", err.Error())
+				return fmt.Errorf("loading outpost commodities: %w", err)
+			}
+
+			for _, outpostCommodity := range outpostCommodities {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+
+				amount := outpostCommodity.GetFloat("amount")
+				expected := ledger[outpostCommodity.Id]
+				if outpostCommodity.GetBool("archived") || math.Abs(amount-expected) <= driftTolerance {
+					stats.Unchanged++
+					continue
+				}
+
+				l.Warn("Outpost commodity drifted from the ledger",
+					"outpost_commodity_id", outpostCommodity.Id,
+					"outpost_id", outpostCommodity.GetString("outpost"),
+					"commodity_id", outpostCommodity.GetString("commodity"),
+					"amount", amount,
+					"ledger", expected)
+
+				outpostCommodity.Set("amount", expected)
+				saved, err := saveRecord(txPb, run, "outpost_commodities", outpostCommodity.Id, outpostCommodity)
+				if err != nil {
+					l.Error("Failed to correct outpost commodity", "outpost_commodity_id", outpostCommodity.Id, "error", err.Error())
+					return err
+				}
+				stats.countUpdate(saved)
+			}
+
+			return nil
+		})
+		if err != nil {
+			l.Error("Inventory reconciliation failed", "error", err.Error())
+			return fmt.Errorf("reconciling inventory: %w", err)
+		}
+	}
+	run.Add("outpost_commodities", stats)
+
+	l.Info("Inventory reconciliation has completed", "drifted", stats.Updated)
+
+	return nil
+}
+
+// ledgerTotals sums the ledger per outpost commodity, of the given outpost commodities only when ids is not nil.
+func ledgerTotals(app core.App, ids []string) (map[string]float64, error) {
+	query := app.DB().
+		Select("outpost_commodity", "SUM(change_amount) AS total").
+		From("outpost_commodity_changes").
+		GroupBy("outpost_commodity")
+
+	if ids != nil {
+		values := make([]any, len(ids))
+		for i, id := range ids {
+			values[i] = id
+		}
+		query = query.Where(dbx.In("outpost_commodity", values...))
+	}
+
+	var totals []ledgerTotal
+	if err := query.All(&totals); err != nil {
+		return nil, err
+	}
+
+	ledger := make(map[string]float64, len(totals))
+	for _, total := range totals {
+		ledger[total.OutpostCommodity] = total.Total
+	}
+	return ledger, nil
+}
@@ -0,0 +1,156 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+
+	"pulsepoint/internal/uex"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// syncCities upserts the landing zone cities of every synced star system.
+func syncCities(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	return syncLocations(ctx, app, run, "cities", client.ListCities, func(city uex.City) (uex.Location, string) {
+		return city.Location, ""
+	})
+}
+
+// syncSurfaceOutposts upserts the surface outposts of every synced star system.
+// They are stored in surface_outposts, outposts being the outposts of the organizations.
+func syncSurfaceOutposts(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	return syncLocations(ctx, app, run, "surface_outposts", client.ListOutposts, func(outpost uex.Outpost) (uex.Location, string) {
+		return outpost.Location, outpost.Nickname
+	})
+}
+
+// syncLocations upserts the landing zones (cities or surface outposts) of every synced star
+// system into the collection, linked to the planet and moon they are on when UEX knows them.
+// Decommissioned or unavailable locations are skipped, and so archived.
+// location returns the shared fields of an upstream record and its nickname, if any.
+func syncLocations[T any](
+	ctx context.Context,
+	app core.App,
+	run *SyncRun,
+	collection string,
+	list func(ctx context.Context, systemID uex.ID) (*uex.Response[T], error),
+	location func(item T) (uex.Location, string),
+) error {
+	l := app.Logger().WithGroup("cronStarSystems")
+
+	locationsCollection, err := findCollection(app, collection)
+	if err != nil {
+		l.Error("Failed to get collection",
+			"error", err.Error())
+		return err
+	}
+
+	fetch := func(ctx context.Context, system *core.Record) (*uex.Response[T], error) {
+		resp, err := list(ctx, uex.ID(system.GetInt("uex_id")))
+		if err != nil {
+			l.Error("Failed to get locations",
+				"collection", collection,
+				"system", system.GetString("name"),
+				"error", err.Error())
+			return nil, upstreamError("fetching "+collection, err)
+		}
+		return resp, nil
+	}
+
+	var existing, planets, moons *recordIndex
+	load := func() error {
+		var err error
+		if existing, err = loadIndex(app, collection, ""); err != nil {
+			return err
+		}
+		if planets, err = loadIndex(app, "planets", ""); err != nil {
+			return err
+		}
+		moons, err = loadIndex(app, "moons", "")
+		return err
+	}
+
+	return syncPerSystem(ctx, app, run, collection, fetch, load, func(system *core.Record, items []T, present map[string]bool) error {
+		l.Info("Updating locations", "collection", collection, "system", system.GetString("name"))
+
+		var stats CollectionStats
+		err := syncTransaction(app, run, func(txPb core.App) error {
+			for _, item := range items {
+				loc, nickname := location(item)
+
+				if !loc.IsAvailable || loc.IsDecommissioned {
+					run.SkipRecord(collection, uexKey(loc.UexID), loc.Name, "not available or decommissioned")
+					stats.Skipped++
+					continue
+				}
+
+				present[uexKey(loc.UexID)] = true
+
+				record := existing.find(loc.UexID, "")
+				if record == nil {
+					record = core.NewRecord(locationsCollection)
+				}
+
+				record.Set("uex_id", int64(loc.UexID))
+				record.Set("name", loc.Name)
+				record.Set("code", loc.Code)
+				record.Set("nickname", nickname)
+				record.Set("star_system", system.Id)
+				record.Set("planet", relationID(planets.get(loc.PlanetID)))
+				record.Set("moon", relationID(moons.get(loc.MoonID)))
+				record.Set("pad_types", loc.PadTypes)
+				record.Set("jurisdiction", loc.Jurisdiction)
+				record.Set("faction", loc.Faction)
+				record.Set("is_armistice", bool(loc.IsArmistice))
+				record.Set("is_landable", bool(loc.IsLandable))
+				record.Set("has_trade_terminal", bool(loc.HasTerminal))
+				record.Set("has_habitation", bool(loc.HasHabitation))
+				record.Set("has_refinery", bool(loc.HasRefinery))
+				record.Set("has_cargo_center", bool(loc.HasCargoCenter))
+				record.Set("has_clinic", bool(loc.HasClinic))
+				record.Set("has_refuel", bool(loc.HasRefuel))
+				record.Set("has_repair", bool(loc.HasRepair))
+				record.Set("has_loading_dock", bool(loc.HasLoadingDock))
+				record.Set("has_docking_port", bool(loc.HasDockingPort))
+				record.Set("has_freight_elevator", bool(loc.HasFreightLift))
+
+				if record.IsNew() {
+					if _, err := saveRecord(txPb, run, collection, uexKey(loc.UexID), record); err != nil {
+						l.Error("Failed to save new location",
+							"collection", collection,
+							"name", loc.Name,
+							"error", err.Error())
+						return err
+					}
+					existing.put(loc.UexID, record)
+					stats.Created++
+					continue
+				}
+
+				if restoreArchived(record) {
+					stats.Restored++
+				}
+
+				saved, err := saveRecord(txPb, run, collection, uexKey(loc.UexID), record)
+				if err != nil {
+					l.Error("Failed to update location",
+						"collection", collection,
+						"name", loc.Name,
+						"error", err.Error())
+					return err
+				}
+				stats.countUpdate(saved)
+			}
+			return nil
+		})
+		if err != nil {
+			l.Error("Location transaction failed",
+				"collection", collection,
+				"error", err.Error())
+			return fmt.Errorf("saving %s: %w", collection, err)
+		}
+		run.Add(collection, stats)
+
+		return nil
+	})
+}
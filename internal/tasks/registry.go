@@ -0,0 +1,43 @@
+package tasks
+
+import "sort"
+
+// registry maps the task names to their implementation.
+var registry = map[string]TaskFunc{
+	TaskCommodities: UpdateCommodities,
+	TaskStarSystems: UpdateStarSystems,
+	TaskTerminals:   UpdateTerminals,
+	TaskInventory:   ReconcileInventory,
+}
+
+// stagedTasks maps the tasks made of stages to the lookup of their single stages.
+var stagedTasks = map[string]func(stage string) (TaskFunc, bool){
+	TaskStarSystems: StarSystemsStage,
+	TaskTerminals:   TerminalsStage,
+}
+
+// Lookup returns the task registered under the given name.
+func Lookup(name string) (TaskFunc, bool) {
+	fn, ok := registry[name]
+	return fn, ok
+}
+
+// Names returns the names of all registered tasks, sorted.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupStage returns a task running only the named stage of the given task.
+// It returns false when the task has no such stage.
+func LookupStage(name string, stage string) (TaskFunc, bool) {
+	lookup, ok := stagedTasks[name]
+	if !ok {
+		return nil, false
+	}
+	return lookup(stage)
+}
@@ -0,0 +1,251 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Names of the tasks managed by the Runner.
+const (
+	TaskCommodities = "commodities"
+	TaskStarSystems = "starSystems"
+	TaskTerminals   = "terminals"
+	TaskInventory   = "inventory"
+)
+
+const (
+	// DefaultRunTimeout bounds a whole task run when no timeout is configured.
+	DefaultRunTimeout = 15 * time.Minute
+
+	// stopGracePeriod is how long Stop waits for cancelled tasks to return.
+	stopGracePeriod = 10 * time.Second
+)
+
+// TaskFunc is the signature shared by all sync tasks.
+// Tasks report what they did through the given SyncRun and return why they failed, if they did.
+type TaskFunc func(ctx context.Context, app core.App, run *SyncRun) error
+
+// activeRun is a task run in progress.
+type activeRun struct {
+	run    *SyncRun
+	cancel context.CancelFunc
+}
+
+// Runner runs the sync tasks on behalf of the cron jobs and the HTTP routes.
+// Every run gets a context derived from the Runner's own context, bounded by the run timeout,
+// so all of them are cancelled when the application terminates.
+// A task never overlaps with itself: a trigger while it is still running is rejected
+// (Run, DryRun) or coalesced into the running one (Start), unless that one is a dry run.
+// Every run is recorded in the sync_runs collection, the id of that record is the job id of the run.
+type Runner struct {
+	app     core.App
+	timeout time.Duration
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[string]*activeRun
+}
+
+// NewRunner creates a new Runner. A zero timeout falls back to DefaultRunTimeout.
+func NewRunner(app core.App, timeout time.Duration) *Runner {
+	if timeout <= 0 {
+		timeout = DefaultRunTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Runner{
+		app:     app,
+		timeout: timeout,
+		ctx:     ctx,
+		cancel:  cancel,
+		running: map[string]*activeRun{},
+	}
+}
+
+// Run executes the named task and blocks until it returns the finished SyncRun.
+// It fails with ErrAlreadyRunning when the task is running and ErrRunnerStopped after Stop.
+func (r *Runner) Run(name string, trigger string, fn TaskFunc) (*SyncRun, error) {
+	l := r.app.Logger().WithGroup("runner")
+
+	run := NewSyncRun(name, trigger)
+	ctx, err := r.reserve(run)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := run.begin(r.app); err != nil {
+		// Not being able to record the run must not prevent the sync itself
+		l.Error("Failed to record sync run", "task", name, "error", err.Error())
+	}
+
+	r.execute(ctx, run, fn)
+
+	return run, nil
+}
+
+// Start launches the named task in the background and returns its SyncRun right away.
+// When the task is already running the trigger is coalesced: the SyncRun of
+// the running task is returned and started is false. A dry run commits nothing,
+// so a trigger is never coalesced into one: it fails with ErrAlreadyRunning instead.
+func (r *Runner) Start(name string, trigger string, fn TaskFunc) (run *SyncRun, started bool, err error) {
+	run = NewSyncRun(name, trigger)
+	ctx, err := r.reserve(run)
+	if errors.Is(err, ErrAlreadyRunning) {
+		if active := r.active(name); active != nil && !active.run.DryRun {
+			return active.run, false, nil
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Without a record there would be no job id to report, so don't start the task at all
+	if err := run.begin(r.app); err != nil {
+		r.release(name)
+		return nil, false, err
+	}
+
+	go r.execute(ctx, run, fn)
+
+	return run, true, nil
+}
+
+// DryRun executes the named task and blocks until it returns, like Run, but every
+// transaction of the task is rolled back (see syncTransaction): nothing is committed,
+// the Diff of the returned SyncRun reports what the task would have written.
+// It fails with ErrAlreadyRunning when the task is running and ErrRunnerStopped after Stop.
+func (r *Runner) DryRun(name string, trigger string, fn TaskFunc) (*SyncRun, error) {
+	l := r.app.Logger().WithGroup("runner")
+
+	run := NewDryRun(name, trigger)
+	ctx, err := r.reserve(run)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := run.begin(r.app); err != nil {
+		l.Error("Failed to record sync run", "task", name, "error", err.Error())
+	}
+
+	r.execute(ctx, run, fn)
+
+	return run, nil
+}
+
+// Find returns the SyncRun of the running task with the given job id, or nil.
+func (r *Runner) Find(id string) *SyncRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, active := range r.running {
+		if active.run.ID() == id {
+			return active.run
+		}
+	}
+	return nil
+}
+
+// IsRunning reports whether the named task is currently running.
+func (r *Runner) IsRunning(name string) bool {
+	return r.active(name) != nil
+}
+
+// Cancel aborts the named task. It returns false if the task wasn't running.
+func (r *Runner) Cancel(name string) bool {
+	active := r.active(name)
+	if active == nil {
+		return false
+	}
+
+	active.cancel()
+	return true
+}
+
+// Stop cancels every running task and waits a short grace period for them to return.
+// No new task can be started afterwards.
+func (r *Runner) Stop() {
+	// Cancel under the lock, so reserve either sees the cancelled context
+	// or adds its task to the wait group before we wait on it
+	r.mu.Lock()
+	r.cancel()
+	r.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(stopGracePeriod):
+		r.app.Logger().Warn("Some tasks did not stop in time")
+	}
+}
+
+// execute runs fn and records the outcome of the run.
+func (r *Runner) execute(ctx context.Context, run *SyncRun, fn TaskFunc) {
+	l := r.app.Logger().WithGroup("runner")
+
+	defer r.release(run.Task)
+
+	if err := fn(ctx, r.app, run); err != nil {
+		l.Error("Task failed", "task", run.Task, "error", err.Error())
+		run.Fail(err)
+	}
+
+	run.markFinished()
+	if err := run.finish(r.app); err != nil {
+		l.Error("Failed to save sync run", "task", run.Task, "error", err.Error())
+	}
+
+	l.Info("Task finished", "task", run.Task, "trigger", run.Trigger, "status", run.Status(), "duration", time.Since(run.StartedAt).String())
+}
+
+// active returns the running instance of the named task, or nil.
+func (r *Runner) active(name string) *activeRun {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.running[name]
+}
+
+// reserve registers the task of run as running and returns the context of the run.
+func (r *Runner) reserve(run *SyncRun) (context.Context, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx.Err() != nil {
+		return nil, ErrRunnerStopped
+	}
+
+	if _, ok := r.running[run.Task]; ok {
+		return nil, ErrAlreadyRunning
+	}
+
+	ctx, cancel := context.WithTimeout(r.ctx, r.timeout)
+	r.running[run.Task] = &activeRun{run: run, cancel: cancel}
+	r.wg.Add(1)
+
+	return ctx, nil
+}
+
+// release frees the context of the named task and marks it as stopped.
+func (r *Runner) release(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if active, ok := r.running[name]; ok {
+		active.cancel()
+		delete(r.running, name)
+		r.wg.Done()
+	}
+}
@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"pulsepoint/internal/uex"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// stageFunc syncs a single stage, its writes are committed independently of the other stages.
+type stageFunc func(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error
+
+// stage is a named step of a staged sync task.
+type stage struct {
+	name string
+	run  stageFunc
+}
+
+// stageTask returns a task running only the named one of the given stages.
+func stageTask(group string, stages []stage, name string) (TaskFunc, bool) {
+	for _, s := range stages {
+		if s.name == name {
+			return func(ctx context.Context, app core.App, run *SyncRun) error {
+				return runStages(ctx, app, run, group, []stage{s})
+			}, true
+		}
+	}
+	return nil, false
+}
+
+// runStages runs the given stages in order and records the outcome of each of them.
+// A failing stage doesn't stop the next ones, the failures are returned joined.
+// group is the logger group of the task.
+func runStages(ctx context.Context, app core.App, run *SyncRun, group string, stages []stage) error {
+	l := app.Logger().WithGroup(group)
+
+	l.Info("Sync has started", "task", run.Task)
+
+	client, err := NewUexClient()
+	if err != nil {
+		l.Error("Failed to create UEX client",
+			"error", err.Error())
+		return err
+	}
+
+	var failures []error
+	for _, s := range stages {
+		// A cancelled run stops right away, the remaining stages are not attempted
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(failures, err)...)
+		}
+
+		run.SetStage(s.name)
+		err := s.run(ctx, app, client, run)
+		run.EndStage(s.name, err)
+
+		if err != nil {
+			l.Error("Stage failed",
+				"stage", s.name,
+				"error", err.Error())
+			failures = append(failures, fmt.Errorf("stage %s: %w", s.name, err))
+			continue
+		}
+
+		l.Info("Stage completed", "stage", s.name)
+	}
+
+	return errors.Join(failures...)
+}
@@ -0,0 +1,589 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"pulsepoint/internal/errs"
+	"pulsepoint/internal/uex"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Stages of the star systems sync, in the order they run.
+const (
+	StageStarSystems   = "star_systems"
+	StagePlanets       = "planets"
+	StageMoons         = "moons"
+	StageSpaceStations = "space_stations"
+	StageCities        = "cities"
+	StageOutposts      = "surface_outposts"
+)
+
+// starSystemStages are the stages of UpdateStarSystems. Every stage relies on the
+// records written by the previous ones: planets link to their star system, moons to
+// their planet and space stations, cities and surface outposts to all three.
+var starSystemStages = []stage{
+	{StageStarSystems, syncStarSystems},
+	{StagePlanets, syncPlanets},
+	{StageMoons, syncMoons},
+	{StageSpaceStations, syncSpaceStations},
+	{StageCities, syncCities},
+	{StageOutposts, syncSurfaceOutposts},
+}
+
+// UpdateStarSystems fetches the star systems from the UEX API and upserts them
+// together with their planets, moons, space stations, cities and surface outposts,
+// one stage after the other.
+// Only systems that are both available and visible are synced.
+//
+// A failing stage doesn't stop the next ones, they work with what is in the database.
+// The outcome of every stage is reported through run, the failures are returned joined.
+// The run stops before the next request or transaction as soon as ctx is done.
+func UpdateStarSystems(ctx context.Context, app core.App, run *SyncRun) error {
+	return runStages(ctx, app, run, "cronStarSystems", starSystemStages)
+}
+
+// StarSystemsStage returns a task running only the named stage of UpdateStarSystems,
+// e.g. to refresh the space stations without touching the rest.
+// The task still runs under the TaskStarSystems name, so it never overlaps with a full sync.
+func StarSystemsStage(name string) (TaskFunc, bool) {
+	return stageTask("cronStarSystems", starSystemStages, name)
+}
+
+// syncStarSystems upserts the systems UEX lists as available and visible and archives the others.
+func syncStarSystems(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	l := app.Logger().WithGroup("cronStarSystems")
+
+	systemsResp, err := client.ListStarSystems(ctx)
+	if err != nil {
+		l.Error("Failed to get star systems",
+			"error", err.Error())
+		return upstreamError("fetching star systems", err)
+	}
+	systems := systemsResp.Data
+	reportInvalid(app, run, "star_systems", systemsResp.Invalid)
+
+	// Filtering out the data
+	var relevantSystems []uex.StarSystem
+	for _, system := range systems {
+		if system.IsAvailable && system.IsVisible {
+			relevantSystems = append(relevantSystems, system)
+		} else {
+			run.SkipRecord("star_systems", uexKey(system.UexID), system.Name, "not available or not visible")
+		}
+	}
+	run.Add("star_systems", CollectionStats{Skipped: len(systems) - len(relevantSystems)})
+
+	// Saving to the database
+	starSystemCollection, err := findCollection(app, "star_systems")
+	if err != nil {
+		l.Error("Failed to get collection",
+			"error", err.Error())
+		return err
+	}
+
+	var systemStats CollectionStats
+	err = syncTransaction(app, run, func(txPb core.App) error {
+		l.Debug("Starting transaction")
+
+		existing, err := loadIndex(txPb, "star_systems", "code")
+		if err != nil {
+			l.Error("Failed to load star systems",
+				"error", err.Error())
+			return err
+		}
+
+		// UEX ids of the systems UEX still lists as available and visible, the others get archived
+		present := map[string]bool{}
+
+		for _, system := range relevantSystems {
+			present[uexKey(system.UexID)] = true
+
+			l.Debug("System",
+				"name", system.Name,
+				"code", system.Code,
+				"uex_id", int64(system.UexID))
+
+			existingSystem := existing.find(system.UexID, system.Code)
+			if existingSystem == nil {
+				l.Debug("System not found, creating new")
+
+				newSystem := core.NewRecord(starSystemCollection)
+				newSystem.Set("uex_id", int64(system.UexID))
+				newSystem.Set("name", system.Name)
+				newSystem.Set("code", system.Code)
+				newSystem.Set("jurisdiction", system.Jurisdiction)
+				newSystem.Set("faction", system.Faction)
+
+				if _, err := saveRecord(txPb, run, "star_systems", uexKey(system.UexID), newSystem); err != nil {
+					l.Error("Failed to save new System",
+						"error", err.Error())
+					return err
+				}
+				existing.put(system.UexID, newSystem)
+				systemStats.Created++
+
+			} else {
+				l.Debug("System found, updating")
+
+				if existingSystem.GetInt("uex_id") == 0 {
+					l.Info("Backfilling the UEX id of system", "name", system.Name, "uex_id", int64(system.UexID))
+				}
+
+				existingSystem.Set("uex_id", int64(system.UexID))
+				existingSystem.Set("name", system.Name)
+				existingSystem.Set("code", system.Code)
+				existingSystem.Set("jurisdiction", system.Jurisdiction)
+				existingSystem.Set("faction", system.Faction)
+				if restoreArchived(existingSystem) {
+					l.Info("System is listed again, restoring it", "name", system.Name)
+					systemStats.Restored++
+				}
+
+				saved, err := saveRecord(txPb, run, "star_systems", uexKey(system.UexID), existingSystem)
+				if err != nil {
+					l.Error("Failed to update System",
+						"error", err.Error())
+					return err
+				}
+				systemStats.countUpdate(saved)
+			}
+		}
+
+		archived, err := archiveMissing(txPb, run, "star_systems", "uex_id", present)
+		if err != nil {
+			l.Error("Failed to archive missing star systems",
+				"error", err.Error())
+			return err
+		}
+		systemStats.Archived += archived
+
+		return nil
+	})
+	if err != nil {
+		l.Error("Star system transaction failed",
+			"error", err.Error())
+		return fmt.Errorf("saving star systems: %w", err)
+	}
+	run.Add("star_systems", systemStats)
+
+	return nil
+}
+
+// syncPlanets upserts the planets of every synced star system.
+func syncPlanets(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	l := app.Logger().WithGroup("cronStarSystems")
+
+	planetsCollection, err := findCollection(app, "planets")
+	if err != nil {
+		l.Error("Failed to get collection",
+			"error", err.Error())
+		return err
+	}
+
+	fetch := func(ctx context.Context, system *core.Record) (*uex.PlanetResponse, error) {
+		planetsResp, err := client.ListPlanets(ctx, uex.ID(system.GetInt("uex_id")))
+		if err != nil {
+			l.Error("Failed to get planets",
+				"system", system.GetString("name"),
+				"error", err.Error())
+			return nil, upstreamError("fetching planets", err)
+		}
+		return planetsResp, nil
+	}
+
+	var existing *recordIndex
+	load := func() error {
+		var err error
+		existing, err = loadIndex(app, "planets", "code")
+		return err
+	}
+
+	return syncPerSystem(ctx, app, run, "planets", fetch, load, func(system *core.Record, planets []uex.Planet, present map[string]bool) error {
+		var planetStats CollectionStats
+		err := syncTransaction(app, run, func(txPb core.App) error {
+			l.Debug("Starting Transaction")
+
+			for _, planet := range planets {
+				present[uexKey(planet.UexID)] = true
+
+				existingPlanet := existing.find(planet.UexID, planet.Code)
+				if existingPlanet == nil {
+					l.Debug("Planet not found, creating new")
+
+					newPlanet := core.NewRecord(planetsCollection)
+					newPlanet.Set("uex_id", int64(planet.UexID))
+					newPlanet.Set("name", planet.Name)
+					newPlanet.Set("code", planet.Code)
+					newPlanet.Set("star_system", system.Id)
+					newPlanet.Set("jurisdiction", planet.Jurisdiction)
+					newPlanet.Set("faction", planet.Faction)
+
+					if _, err := saveRecord(txPb, run, "planets", uexKey(planet.UexID), newPlanet); err != nil {
+						l.Error("Failed to save new planet",
+							"error", err.Error())
+						return err
+					}
+					existing.put(planet.UexID, newPlanet)
+					planetStats.Created++
+				} else {
+					l.Debug("Planet found, updating")
+
+					existingPlanet.Set("uex_id", int64(planet.UexID))
+					existingPlanet.Set("name", planet.Name)
+					existingPlanet.Set("code", planet.Code)
+					existingPlanet.Set("star_system", system.Id)
+					existingPlanet.Set("jurisdiction", planet.Jurisdiction)
+					existingPlanet.Set("faction", planet.Faction)
+					if restoreArchived(existingPlanet) {
+						planetStats.Restored++
+					}
+
+					saved, err := saveRecord(txPb, run, "planets", uexKey(planet.UexID), existingPlanet)
+					if err != nil {
+						l.Error("Failed to update planet",
+							"error", err.Error())
+						return err
+					}
+					planetStats.countUpdate(saved)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			l.Error("Planet transaction failed",
+				"error", err.Error())
+			return fmt.Errorf("saving planets: %w", err)
+		}
+		run.Add("planets", planetStats)
+
+		return nil
+	})
+}
+
+// syncMoons upserts the moons of every synced star system.
+// Moons whose planet is unknown are skipped.
+func syncMoons(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	l := app.Logger().WithGroup("cronStarSystems")
+
+	moonsCollection, err := findCollection(app, "moons")
+	if err != nil {
+		l.Error("Failed to get collection",
+			"error", err.Error())
+		return err
+	}
+
+	fetch := func(ctx context.Context, system *core.Record) (*uex.MoonResponse, error) {
+		moonsResp, err := client.ListMoons(ctx, uex.ID(system.GetInt("uex_id")))
+		if err != nil {
+			l.Error("Failed to get moons",
+				"system", system.GetString("name"),
+				"error", err.Error())
+			return nil, upstreamError("fetching moons", err)
+		}
+		return moonsResp, nil
+	}
+
+	var existing, planets *recordIndex
+	load := func() error {
+		var err error
+		if existing, err = loadIndex(app, "moons", "code"); err != nil {
+			return err
+		}
+		planets, err = loadIndex(app, "planets", "")
+		return err
+	}
+
+	return syncPerSystem(ctx, app, run, "moons", fetch, load, func(system *core.Record, moons []uex.Moon, present map[string]bool) error {
+		var moonStats CollectionStats
+		err := syncTransaction(app, run, func(txPb core.App) error {
+			l.Debug("Starting Transaction")
+
+			for _, moon := range moons {
+				present[uexKey(moon.UexID)] = true
+
+				// The planet is looked up by its UEX id, names are not unique across systems
+				existingPlanet := planets.get(moon.PlanetID)
+				if existingPlanet == nil {
+					l.Debug("Planet of Moon not found",
+						"moon", moon.Name,
+						"id_planet", int64(moon.PlanetID))
+					run.SkipRecord("moons", uexKey(moon.UexID), moon.Name, fmt.Sprintf("planet %d (%s) not found", moon.PlanetID, moon.PlanetName))
+					moonStats.Skipped++
+					continue
+				}
+
+				existingMoon := existing.find(moon.UexID, moon.Code)
+				if existingMoon == nil {
+					l.Debug("Moon not found, creating new")
+
+					newMoon := core.NewRecord(moonsCollection)
+					newMoon.Set("uex_id", int64(moon.UexID))
+					newMoon.Set("name", moon.Name)
+					newMoon.Set("code", moon.Code)
+					newMoon.Set("planet", existingPlanet.Id)
+					newMoon.Set("jurisdiction", moon.Jurisdiction)
+					newMoon.Set("faction", moon.Faction)
+
+					if _, err := saveRecord(txPb, run, "moons", uexKey(moon.UexID), newMoon); err != nil {
+						l.Error("Failed to save new moon",
+							"error", err.Error())
+						return err
+					}
+					existing.put(moon.UexID, newMoon)
+					moonStats.Created++
+				} else {
+					l.Debug("Moon found, updating")
+
+					existingMoon.Set("uex_id", int64(moon.UexID))
+					existingMoon.Set("name", moon.Name)
+					existingMoon.Set("code", moon.Code)
+					existingMoon.Set("planet", existingPlanet.Id)
+					existingMoon.Set("jurisdiction", moon.Jurisdiction)
+					existingMoon.Set("faction", moon.Faction)
+					if restoreArchived(existingMoon) {
+						moonStats.Restored++
+					}
+
+					saved, err := saveRecord(txPb, run, "moons", uexKey(moon.UexID), existingMoon)
+					if err != nil {
+						l.Error("Failed to update moon",
+							"error", err.Error())
+						return err
+					}
+					moonStats.countUpdate(saved)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			l.Error("Moon transaction failed",
+				"error", err.Error())
+			return fmt.Errorf("saving moons: %w", err)
+		}
+		run.Add("moons", moonStats)
+
+		return nil
+	})
+}
+
+// syncSpaceStations upserts the space stations of every synced star system,
+// linked to the planet and moon they orbit when UEX knows them.
+func syncSpaceStations(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	l := app.Logger().WithGroup("cronStarSystems")
+
+	spaceStationsCollection, err := findCollection(app, "space_stations")
+	if err != nil {
+		l.Error("Failed to get collection",
+			"error", err.Error())
+		return err
+	}
+
+	fetch := func(ctx context.Context, system *core.Record) (*uex.SpaceStationResponse, error) {
+		spaceStationsResp, err := client.ListSpaceStations(ctx, uex.ID(system.GetInt("uex_id")))
+		if err != nil {
+			l.Error("Failed to get space stations",
+				"system", system.GetString("name"),
+				"error", err.Error())
+			return nil, upstreamError("fetching space stations", err)
+		}
+		return spaceStationsResp, nil
+	}
+
+	var existing, planets, moons *recordIndex
+	load := func() error {
+		var err error
+		if existing, err = loadIndex(app, "space_stations", "name"); err != nil {
+			return err
+		}
+		if planets, err = loadIndex(app, "planets", ""); err != nil {
+			return err
+		}
+		moons, err = loadIndex(app, "moons", "")
+		return err
+	}
+
+	return syncPerSystem(ctx, app, run, "space_stations", fetch, load, func(system *core.Record, spaceStations []uex.SpaceStation, present map[string]bool) error {
+		l.Info("Updating space stations", "system", system.GetString("name"))
+
+		var spaceStationStats CollectionStats
+		err := syncTransaction(app, run, func(txPb core.App) error {
+			l.Debug("Starting Transaction")
+
+			for _, spaceStation := range spaceStations {
+				present[uexKey(spaceStation.UexID)] = true
+
+				existingPlanet := planets.get(spaceStation.PlanetID)
+				if existingPlanet == nil {
+					l.Debug("Space Station is not orbiting a planet", "name", spaceStation.Name)
+				}
+
+				existingMoon := moons.get(spaceStation.MoonID)
+				if existingMoon == nil {
+					l.Debug("Space Station is not orbiting a moon", "name", spaceStation.Name)
+				}
+
+				existingSpaceStation := existing.find(spaceStation.UexID, spaceStation.Name)
+				if existingSpaceStation == nil {
+					l.Debug("Space Station not found, creating new")
+
+					newSpaceStation := core.NewRecord(spaceStationsCollection)
+					newSpaceStation.Set("uex_id", int64(spaceStation.UexID))
+					newSpaceStation.Set("name", spaceStation.Name)
+					newSpaceStation.Set("pad_types", spaceStation.PadTypes)
+					newSpaceStation.Set("jurisdiction", spaceStation.Jurisdiction)
+					newSpaceStation.Set("faction", spaceStation.Faction)
+					newSpaceStation.Set("has_trade_terminal", bool(spaceStation.HasTerminal))
+					newSpaceStation.Set("has_refinery", bool(spaceStation.HasRefinery))
+					newSpaceStation.Set("star_system", system.Id)
+					if existingPlanet != nil {
+						newSpaceStation.Set("planet", existingPlanet.Id)
+					}
+					if existingMoon != nil {
+						newSpaceStation.Set("moon", existingMoon.Id)
+					}
+					newSpaceStation.Set("orbit", spaceStation.Orbit)
+					newSpaceStation.Set("is_lagrange", bool(spaceStation.IsLagrange))
+
+					if _, err := saveRecord(txPb, run, "space_stations", uexKey(spaceStation.UexID), newSpaceStation); err != nil {
+						l.Error("Failed to save new space station",
+							"error", err.Error())
+						return err
+					}
+					existing.put(spaceStation.UexID, newSpaceStation)
+					spaceStationStats.Created++
+
+				} else {
+					l.Debug("Space Station found, updating")
+
+					existingSpaceStation.Set("uex_id", int64(spaceStation.UexID))
+					existingSpaceStation.Set("name", spaceStation.Name)
+					existingSpaceStation.Set("pad_types", spaceStation.PadTypes)
+					existingSpaceStation.Set("jurisdiction", spaceStation.Jurisdiction)
+					existingSpaceStation.Set("faction", spaceStation.Faction)
+					existingSpaceStation.Set("has_trade_terminal", bool(spaceStation.HasTerminal))
+					existingSpaceStation.Set("has_refinery", bool(spaceStation.HasRefinery))
+					existingSpaceStation.Set("star_system", system.Id)
+					if existingPlanet != nil {
+						existingSpaceStation.Set("planet", existingPlanet.Id)
+					}
+					if existingMoon != nil {
+						existingSpaceStation.Set("moon", existingMoon.Id)
+					}
+					existingSpaceStation.Set("orbit", spaceStation.Orbit)
+					existingSpaceStation.Set("is_lagrange", bool(spaceStation.IsLagrange))
+					if restoreArchived(existingSpaceStation) {
+						spaceStationStats.Restored++
+					}
+
+					saved, err := saveRecord(txPb, run, "space_stations", uexKey(spaceStation.UexID), existingSpaceStation)
+					if err != nil {
+						l.Error("Failed to update space station",
+							"error", err.Error())
+						return err
+					}
+					spaceStationStats.countUpdate(saved)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			l.Error("Space station transaction failed",
+				"error", err.Error())
+			return fmt.Errorf("saving space stations: %w", err)
+		}
+		run.Add("space_stations", spaceStationStats)
+
+		return nil
+	})
+}
+
+// syncPerSystem syncs the records of the collection for every synced star system.
+// The responses of all systems are fetched first, concurrently (see fetchPerSystem).
+// They are then written one system after the other, in the order of the system names,
+// each system being committed on its own. write adds the UEX ids it saw to present.
+//
+// load builds the record indexes the writes use, once for the whole stage, and the writes
+// keep them up to date. It runs again after a failed system: its writes were rolled back,
+// so the indexes no longer match the database.
+//
+// A failing system doesn't stop the others, but then nothing gets archived:
+// a partial sync can't tell which records really disappeared upstream.
+func syncPerSystem[T any](
+	ctx context.Context,
+	app core.App,
+	run *SyncRun,
+	collection string,
+	fetch func(ctx context.Context, system *core.Record) (*uex.Response[T], error),
+	load func() error,
+	write func(system *core.Record, items []T, present map[string]bool) error,
+) error {
+	l := app.Logger().WithGroup("cronStarSystems")
+
+	systems, err := app.FindRecordsByFilter("star_systems", "archived = false && uex_id != 0", "name", 0, 0)
+	if err != nil {
+		l.Error("Failed to get the synced star systems",
+			"error", err.Error())
+		return fmt.Errorf("%w: star_systems: %w", errs.ErrCollectionMissing, err)
+	}
+
+	results := fetchPerSystem(ctx, systems, SyncWorkers(), fetch)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	present := map[string]bool{}
+	var failures []error
+	stale := true
+
+	for i, system := range systems {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := results[i].err
+		if err == nil && stale {
+			if err = load(); err == nil {
+				stale = false
+			}
+		}
+		if err == nil {
+			reportInvalid(app, run, collection, results[i].resp.Invalid)
+			if err = write(system, results[i].resp.Data, present); err != nil {
+				stale = true
+			}
+		}
+
+		if err != nil {
+			l.Error("Failed to sync star system",
+				"collection", collection,
+				"system", system.GetString("name"),
+				"error", err.Error())
+			failures = append(failures, fmt.Errorf("%s: %w", system.GetString("name"), err))
+		}
+	}
+
+	if len(failures) > 0 {
+		l.Warn("Some star systems failed, skipping archiving",
+			"collection", collection)
+		return errors.Join(failures...)
+	}
+
+	var archived int
+	err = syncTransaction(app, run, func(txPb core.App) error {
+		var err error
+		archived, err = archiveMissing(txPb, run, collection, "uex_id", present)
+		return err
+	})
+	if err != nil {
+		l.Error("Failed to archive missing records",
+			"collection", collection,
+			"error", err.Error())
+		return fmt.Errorf("archiving missing %s: %w", collection, err)
+	}
+	run.Add(collection, CollectionStats{Archived: archived})
+
+	return nil
+}
@@ -0,0 +1,400 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"pulsepoint/internal/errs"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Triggers of a sync run.
+const (
+	TriggerCron   = "cron"
+	TriggerManual = "manual"
+)
+
+// Statuses of a sync run.
+const (
+	SyncStatusRunning   = "running"
+	SyncStatusSuccess   = "success"
+	SyncStatusFailed    = "failed"
+	SyncStatusCancelled = "cancelled"
+)
+
+// CollectionStats counts what a sync run did to the records of one collection.
+type CollectionStats struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Skipped   int `json:"skipped"`
+	Failed    int `json:"failed"`
+	Archived  int `json:"archived"`
+	Restored  int `json:"restored"`
+}
+
+// countUpdate counts an existing record, as updated when it was saved, as unchanged otherwise.
+func (s *CollectionStats) countUpdate(saved bool) {
+	if saved {
+		s.Updated++
+	} else {
+		s.Unchanged++
+	}
+}
+
+// add merges other into s.
+func (s *CollectionStats) add(other CollectionStats) {
+	s.Created += other.Created
+	s.Updated += other.Updated
+	s.Unchanged += other.Unchanged
+	s.Skipped += other.Skipped
+	s.Failed += other.Failed
+	s.Archived += other.Archived
+	s.Restored += other.Restored
+}
+
+// StageStatus is the outcome of one stage of a staged task, e.g. the planets of UpdateStarSystems.
+type StageStatus struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"errorCode,omitempty"`
+}
+
+// SyncRun collects the outcome of a single task execution and persists it
+// in the sync_runs collection, so admins can see in the dashboard when data
+// was last refreshed and whether the last run actually worked.
+type SyncRun struct {
+	Task      string
+	Trigger   string
+	StartedAt time.Time
+
+	// DryRun runs are rolled back, their Diff reports what would have been written.
+	DryRun bool
+
+	mu         sync.Mutex
+	stage      string
+	stages     []StageStatus
+	stats      map[string]*CollectionStats
+	err        error
+	finishedAt time.Time
+	record     *core.Record
+	done       chan struct{}
+	diff       *Diff
+	changes    []*RecordChangeEvent
+}
+
+// SyncRunStatus is the JSON representation of a sync run, as reported by the jobs endpoint.
+type SyncRunStatus struct {
+	ID         string `json:"id"`
+	Task       string `json:"task"`
+	Trigger    string `json:"trigger"`
+	DryRun     bool   `json:"dryRun"`
+	Status     string `json:"status"`
+	Stage      string `json:"stage,omitempty"`
+	Stages     any    `json:"stages,omitempty"`
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+	Stats      any    `json:"stats"`
+	Error      string `json:"error,omitempty"`
+	ErrorCode  string `json:"errorCode,omitempty"`
+
+	// Diff is only reported for dry runs, it is stored with the run once finished.
+	Diff map[string]*CollectionDiff `json:"diff,omitempty"`
+}
+
+// NewSyncRun creates a new SyncRun for the given task and trigger.
+func NewSyncRun(task string, trigger string) *SyncRun {
+	return &SyncRun{
+		Task:      task,
+		Trigger:   trigger,
+		StartedAt: time.Now(),
+		stats:     map[string]*CollectionStats{},
+		done:      make(chan struct{}),
+	}
+}
+
+// NewDryRun creates a new SyncRun whose writes are collected into a Diff.
+func NewDryRun(task string, trigger string) *SyncRun {
+	run := NewSyncRun(task, trigger)
+	run.DryRun = true
+	run.diff = NewDiff()
+	return run
+}
+
+// Diff returns the diff collected by a dry run, nil for a regular run.
+func (r *SyncRun) Diff() *Diff {
+	return r.diff
+}
+
+// SkipRecord reports an upstream record ignored by the sync, with the reason why.
+func (r *SyncRun) SkipRecord(collection string, key string, name string, reason string) {
+	if r.diff != nil {
+		r.diff.addSkip(collection, key, name, reason)
+	}
+}
+
+// trackChange queues the change of a saved record until its transaction is committed.
+// Dry runs never commit, their changes are not tracked.
+func (r *SyncRun) trackChange(event *RecordChangeEvent) {
+	if r.DryRun {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.changes = append(r.changes, event)
+}
+
+// takeChanges returns the queued changes and clears the queue.
+func (r *SyncRun) takeChanges() []*RecordChangeEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changes := r.changes
+	r.changes = nil
+	return changes
+}
+
+// Done returns a channel that is closed once the run finished.
+func (r *SyncRun) Done() <-chan struct{} {
+	return r.done
+}
+
+// ID returns the id of the sync_runs record of the run, which is also its job id.
+// It is empty when the run could not be recorded.
+func (r *SyncRun) ID() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.record == nil {
+		return ""
+	}
+	return r.record.Id
+}
+
+// SetStage records which part of the task is currently running, e.g. "planets".
+func (r *SyncRun) SetStage(stage string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stage = stage
+}
+
+// EndStage records the outcome of the given stage, err being nil when it succeeded.
+func (r *SyncRun) EndStage(stage string, err error) {
+	status := StageStatus{Name: stage, Status: SyncStatusSuccess}
+	switch {
+	case err == nil:
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		status.Status = SyncStatusCancelled
+	default:
+		status.Status = SyncStatusFailed
+	}
+	if err != nil {
+		status.Error = err.Error()
+		status.ErrorCode = errs.Code(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stages = append(r.stages, status)
+}
+
+// Stages returns a copy of the outcomes of the stages that ended so far.
+func (r *SyncRun) Stages() []StageStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]StageStatus(nil), r.stages...)
+}
+
+// Add merges the stats of a committed batch into the stats of the given collection.
+// Tasks should only call it once their transaction succeeded, so the counts reflect what was actually written.
+func (r *SyncRun) Add(collection string, stats CollectionStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.stats[collection]
+	if !ok {
+		existing = &CollectionStats{}
+		r.stats[collection] = existing
+	}
+	existing.add(stats)
+}
+
+// Fail marks the run as failed. Only the first error is kept.
+func (r *SyncRun) Fail(err error) {
+	if err == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// Err returns the error the run failed with, if any.
+func (r *SyncRun) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+// Stats returns a copy of the per-collection stats.
+func (r *SyncRun) Stats() map[string]CollectionStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := make(map[string]CollectionStats, len(r.stats))
+	for collection, s := range r.stats {
+		stats[collection] = *s
+	}
+	return stats
+}
+
+// Status returns the status of the run, "running" until the task returned.
+func (r *SyncRun) Status() string {
+	r.mu.Lock()
+	finished := !r.finishedAt.IsZero()
+	err := r.err
+	r.mu.Unlock()
+
+	switch {
+	case !finished:
+		return SyncStatusRunning
+	case err == nil:
+		return SyncStatusSuccess
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return SyncStatusCancelled
+	default:
+		return SyncStatusFailed
+	}
+}
+
+// Snapshot returns the current state of the run.
+func (r *SyncRun) Snapshot() SyncRunStatus {
+	status := SyncRunStatus{
+		ID:        r.ID(),
+		Task:      r.Task,
+		Trigger:   r.Trigger,
+		DryRun:    r.DryRun,
+		Status:    r.Status(),
+		StartedAt: r.StartedAt.UTC().Format(types.DefaultDateLayout),
+		Stats:     r.Stats(),
+	}
+	if stages := r.Stages(); len(stages) > 0 {
+		status.Stages = stages
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status.Stage = r.stage
+	if !r.finishedAt.IsZero() {
+		status.FinishedAt = r.finishedAt.UTC().Format(types.DefaultDateLayout)
+	}
+	if r.err != nil {
+		status.Error = r.err.Error()
+		status.ErrorCode = errs.Code(r.err)
+	}
+	if r.diff != nil {
+		status.Diff = r.diff.Collections()
+	}
+
+	return status
+}
+
+// SyncRunStatusFromRecord builds the status of a finished (or interrupted) run from its sync_runs record.
+func SyncRunStatusFromRecord(record *core.Record) SyncRunStatus {
+	status := SyncRunStatus{
+		ID:        record.Id,
+		Task:      record.GetString("task"),
+		Trigger:   record.GetString("trigger"),
+		DryRun:    record.GetBool("dry_run"),
+		Status:    record.GetString("status"),
+		StartedAt: record.GetDateTime("started_at").String(),
+		Stats:     record.Get("stats"),
+		Stages:    record.Get("stages"),
+		Error:     record.GetString("error"),
+		ErrorCode: record.GetString("error_code"),
+	}
+
+	if finishedAt := record.GetDateTime("finished_at"); !finishedAt.IsZero() {
+		status.FinishedAt = finishedAt.String()
+	}
+
+	if status.DryRun {
+		var diff map[string]*CollectionDiff
+		if err := record.UnmarshalJSONField("diff", &diff); err == nil {
+			status.Diff = diff
+		}
+	}
+
+	return status
+}
+
+// markFinished stops the clock of the run, its status is final from now on.
+func (r *SyncRun) markFinished() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.finishedAt = time.Now()
+	close(r.done)
+}
+
+// begin creates the sync_runs record with the "running" status.
+func (r *SyncRun) begin(app core.App) error {
+	collection, err := app.FindCollectionByNameOrId("sync_runs")
+	if err != nil {
+		return err
+	}
+
+	record := core.NewRecord(collection)
+	record.Set("task", r.Task)
+	record.Set("trigger", r.Trigger)
+	record.Set("dry_run", r.DryRun)
+	record.Set("status", SyncStatusRunning)
+	record.Set("started_at", r.StartedAt)
+
+	if err := app.Save(record); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.record = record
+	r.mu.Unlock()
+
+	return nil
+}
+
+// finish stores the final status, the stats, the error and the diff of the run.
+func (r *SyncRun) finish(app core.App) error {
+	if r.record == nil {
+		return nil
+	}
+
+	r.record.Set("status", r.Status())
+	r.record.Set("finished_at", r.finishedAt)
+	r.record.Set("stats", r.Stats())
+	r.record.Set("stages", r.Stages())
+	if err := r.Err(); err != nil {
+		r.record.Set("error", err.Error())
+		r.record.Set("error_code", errs.Code(err))
+	}
+	if diff := r.Diff(); diff != nil {
+		r.record.Set("diff", diff.Collections())
+	}
+
+	return app.Save(r.record)
+}
@@ -0,0 +1,297 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"pulsepoint/internal/uex"
+
+	"github.com/pocketbase/pocketbase/core"
+)
+
+// Stages of the terminals sync, in the order they run.
+const (
+	StageTerminals      = "terminals"
+	StageTerminalPrices = "terminal_commodity_prices"
+)
+
+// terminalStages are the stages of UpdateTerminals.
+// The prices link to the terminals synced by the first stage and to the synced commodities.
+var terminalStages = []stage{
+	{StageTerminals, syncTerminals},
+	{StageTerminalPrices, syncTerminalPrices},
+}
+
+// UpdateTerminals fetches the commodity trade terminals from the UEX API, linked to the
+// synced star systems, planets, moons, space stations, cities and surface outposts,
+// and the commodity prices of every terminal. Like UpdateStarSystems it runs in stages,
+// see runStages.
+func UpdateTerminals(ctx context.Context, app core.App, run *SyncRun) error {
+	return runStages(ctx, app, run, "cronTerminals", terminalStages)
+}
+
+// TerminalsStage returns a task running only the named stage of UpdateTerminals.
+func TerminalsStage(name string) (TaskFunc, bool) {
+	return stageTask("cronTerminals", terminalStages, name)
+}
+
+// syncTerminals upserts the available terminals and archives the others.
+func syncTerminals(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	l := app.Logger().WithGroup("cronTerminals")
+
+	terminalsResp, err := client.ListTerminals(ctx)
+	if err != nil {
+		l.Error("Failed to get terminals",
+			"error", err.Error())
+		return upstreamError("fetching terminals", err)
+	}
+	reportInvalid(app, run, "terminals", terminalsResp.Invalid)
+
+	terminalsCollection, err := findCollection(app, "terminals")
+	if err != nil {
+		l.Error("Failed to get collection",
+			"error", err.Error())
+		return err
+	}
+
+	var terminalStats CollectionStats
+	err = syncTransaction(app, run, func(txPb core.App) error {
+		existing, err := loadIndex(txPb, "terminals", "")
+		if err != nil {
+			return err
+		}
+
+		// The locations a terminal can be linked to
+		locations := map[string]*recordIndex{}
+		for _, collection := range []string{"star_systems", "planets", "moons", "space_stations", "cities", "surface_outposts"} {
+			if locations[collection], err = loadIndex(txPb, collection, ""); err != nil {
+				return err
+			}
+		}
+
+		// UEX ids of the terminals UEX still lists as available, the others get archived
+		present := map[string]bool{}
+
+		for _, terminal := range terminalsResp.Data {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if !terminal.IsAvailable {
+				run.SkipRecord("terminals", uexKey(terminal.UexID), terminal.Name, "not available")
+				terminalStats.Skipped++
+				continue
+			}
+
+			present[uexKey(terminal.UexID)] = true
+
+			record := existing.find(terminal.UexID, "")
+			if record == nil {
+				record = core.NewRecord(terminalsCollection)
+			}
+
+			record.Set("uex_id", int64(terminal.UexID))
+			record.Set("name", terminal.Name)
+			record.Set("nickname", terminal.Nickname)
+			record.Set("code", terminal.Code)
+			record.Set("type", terminal.Type)
+			record.Set("star_system", relationID(locations["star_systems"].get(terminal.StarSystemID)))
+			record.Set("planet", relationID(locations["planets"].get(terminal.PlanetID)))
+			record.Set("moon", relationID(locations["moons"].get(terminal.MoonID)))
+			record.Set("space_station", relationID(locations["space_stations"].get(terminal.SpaceStationID)))
+			record.Set("city", relationID(locations["cities"].get(terminal.CityID)))
+			record.Set("surface_outpost", relationID(locations["surface_outposts"].get(terminal.OutpostID)))
+
+			if record.IsNew() {
+				if _, err := saveRecord(txPb, run, "terminals", uexKey(terminal.UexID), record); err != nil {
+					l.Error("Failed to save new terminal",
+						"name", terminal.Name,
+						"error", err.Error())
+					return err
+				}
+				existing.put(terminal.UexID, record)
+				terminalStats.Created++
+				continue
+			}
+
+			if restoreArchived(record) {
+				terminalStats.Restored++
+			}
+
+			saved, err := saveRecord(txPb, run, "terminals", uexKey(terminal.UexID), record)
+			if err != nil {
+				l.Error("Failed to update terminal",
+					"name", terminal.Name,
+					"error", err.Error())
+				return err
+			}
+			terminalStats.countUpdate(saved)
+		}
+
+		archived, err := archiveMissing(txPb, run, "terminals", "uex_id", present)
+		if err != nil {
+			return err
+		}
+		terminalStats.Archived += archived
+
+		return nil
+	})
+	if err != nil {
+		l.Error("Terminal transaction failed",
+			"error", err.Error())
+		return fmt.Errorf("saving terminals: %w", err)
+	}
+	run.Add("terminals", terminalStats)
+
+	return nil
+}
+
+// syncTerminalPrices upserts the commodity prices of every synced terminal and archives
+// the prices UEX doesn't report anymore. Prices of unknown or archived terminals or commodities
+// (e.g. the filtered out temporary commodities) are skipped.
+// A price is matched by its UEX id, or else by its terminal and commodity: there is a single
+// price per pair, UEX may report it under a new id.
+func syncTerminalPrices(ctx context.Context, app core.App, client *uex.Client, run *SyncRun) error {
+	l := app.Logger().WithGroup("cronTerminals")
+
+	pricesResp, err := client.ListCommodityPrices(ctx)
+	if err != nil {
+		l.Error("Failed to get terminal prices",
+			"error", err.Error())
+		return upstreamError("fetching terminal prices", err)
+	}
+	reportInvalid(app, run, "terminal_commodity_prices", pricesResp.Invalid)
+
+	pricesCollection, err := findCollection(app, "terminal_commodity_prices")
+	if err != nil {
+		l.Error("Failed to get collection",
+			"error", err.Error())
+		return err
+	}
+
+	var priceStats CollectionStats
+	err = syncTransaction(app, run, func(txPb core.App) error {
+		existing, err := loadIndex(txPb, "terminal_commodity_prices", "")
+		if err != nil {
+			return err
+		}
+		terminals, err := loadIndex(txPb, "terminals", "")
+		if err != nil {
+			return err
+		}
+		commodities, err := loadIndex(txPb, "commodities", "")
+		if err != nil {
+			return err
+		}
+
+		byPair := make(map[string]*core.Record, len(existing.byUexID))
+		for _, record := range existing.byUexID {
+			byPair[record.GetString("terminal")+"/"+record.GetString("commodity")] = record
+		}
+
+		// UEX ids of the prices UEX still reports, the others get archived
+		present := map[string]bool{}
+
+		for _, price := range pricesResp.Data {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			key := uexKey(price.UexID)
+
+			terminal := terminals.get(price.TerminalID)
+			if terminal == nil {
+				run.SkipRecord("terminal_commodity_prices", key, "", fmt.Sprintf("terminal %d not synced", price.TerminalID))
+				priceStats.Skipped++
+				continue
+			}
+			if terminal.GetBool("archived") {
+				run.SkipRecord("terminal_commodity_prices", key, "", fmt.Sprintf("terminal %d archived", price.TerminalID))
+				priceStats.Skipped++
+				continue
+			}
+
+			commodity := commodities.get(price.CommodityID)
+			if commodity == nil {
+				run.SkipRecord("terminal_commodity_prices", key, "", fmt.Sprintf("commodity %d not synced", price.CommodityID))
+				priceStats.Skipped++
+				continue
+			}
+			if commodity.GetBool("archived") {
+				run.SkipRecord("terminal_commodity_prices", key, "", fmt.Sprintf("commodity %d archived", price.CommodityID))
+				priceStats.Skipped++
+				continue
+			}
+
+			present[key] = true
+
+			pair := terminal.Id + "/" + commodity.Id
+			record := existing.find(price.UexID, "")
+			if record == nil {
+				record = byPair[pair]
+			}
+			if record == nil {
+				record = core.NewRecord(pricesCollection)
+			}
+
+			record.Set("uex_id", int64(price.UexID))
+			record.Set("terminal", terminal.Id)
+			record.Set("commodity", commodity.Id)
+			record.Set("price_buy", float64(price.PriceBuy))
+			record.Set("price_sell", float64(price.PriceSell))
+			record.Set("scu_buy", float64(price.ScuBuy))
+			record.Set("scu_sell_stock", float64(price.ScuSellStock))
+			record.Set("scu_sell", float64(price.ScuSell))
+			record.Set("status_buy", int64(price.StatusBuy))
+			record.Set("status_sell", int64(price.StatusSell))
+			if price.DateModified > 0 {
+				record.Set("reported_at", time.Unix(int64(price.DateModified), 0).UTC())
+			}
+
+			if record.IsNew() {
+				if _, err := saveRecord(txPb, run, "terminal_commodity_prices", key, record); err != nil {
+					l.Error("Failed to save new terminal price",
+						"terminal", terminal.GetString("name"),
+						"commodity", commodity.GetString("name"),
+						"error", err.Error())
+					return err
+				}
+				existing.put(price.UexID, record)
+				byPair[pair] = record
+				priceStats.Created++
+				continue
+			}
+
+			if restoreArchived(record) {
+				priceStats.Restored++
+			}
+
+			saved, err := saveRecord(txPb, run, "terminal_commodity_prices", key, record)
+			if err != nil {
+				l.Error("Failed to update terminal price",
+					"terminal", terminal.GetString("name"),
+					"commodity", commodity.GetString("name"),
+					"error", err.Error())
+				return err
+			}
+			priceStats.countUpdate(saved)
+		}
+
+		archived, err := archiveMissing(txPb, run, "terminal_commodity_prices", "uex_id", present)
+		if err != nil {
+			return err
+		}
+		priceStats.Archived += archived
+
+		return nil
+	})
+	if err != nil {
+		l.Error("Terminal price transaction failed",
+			"error", err.Error())
+		return fmt.Errorf("saving terminal prices: %w", err)
+	}
+	run.Add("terminal_commodity_prices", priceStats)
+
+	return nil
+}
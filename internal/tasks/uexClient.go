@@ -0,0 +1,47 @@
+package tasks
+
+import (
+	"fmt"
+
+	"pulsepoint/internal/errs"
+	"pulsepoint/internal/uex"
+
+	"github.com/spf13/viper"
+)
+
+// NewUexClient builds a UEX API client from the loaded configuration.
+//
+// The following config keys are read:
+//   - UEX_API_URL (required): the API root, e.g. https://uexcorp.space/api/2.0
+//   - UEX_API_KEY: the Bearer token used to authenticate against UEX
+//   - UEX_TIMEOUT: per-request timeout as a duration string (default 30s)
+//   - UEX_USER_AGENT: the User-Agent sent with every request
+//   - UEX_MAX_ATTEMPTS: tries per request before giving up (default 4)
+//   - UEX_RATE_LIMIT: sustained requests per second to the UEX host (default 2)
+//   - UEX_RATE_BURST: requests allowed back to back before throttling (default 5)
+//   - UEX_BREAKER_THRESHOLD: consecutive failed requests that abort the sync (default 5)
+//   - UEX_BREAKER_COOLDOWN: how long UEX is considered down once the breaker opened (default 1m)
+func NewUexClient() (*uex.Client, error) {
+	uexApiUrl := viper.GetString("UEX_API_URL")
+	if uexApiUrl == "" {
+		return nil, fmt.Errorf("%w: UEX_API_URL is not set", errs.ErrMisconfigured)
+	}
+
+	client, err := uex.NewClient(uex.Config{
+		BaseURL:   uexApiUrl,
+		APIKey:    viper.GetString("UEX_API_KEY"),
+		Timeout:   viper.GetDuration("UEX_TIMEOUT"),
+		UserAgent: viper.GetString("UEX_USER_AGENT"),
+
+		MaxAttempts:       viper.GetInt("UEX_MAX_ATTEMPTS"),
+		RequestsPerSecond: viper.GetFloat64("UEX_RATE_LIMIT"),
+		Burst:             viper.GetInt("UEX_RATE_BURST"),
+		BreakerThreshold:  viper.GetInt("UEX_BREAKER_THRESHOLD"),
+		BreakerCooldown:   viper.GetDuration("UEX_BREAKER_COOLDOWN"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errs.ErrMisconfigured, err)
+	}
+
+	return client, nil
+}
@@ -0,0 +1,69 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+
+	"pulsepoint/internal/uex"
+
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/spf13/viper"
+)
+
+// DefaultSyncWorkers is the number of concurrent UEX requests of a sync when SYNC_WORKERS is not set.
+const DefaultSyncWorkers = 4
+
+// SyncWorkers returns the number of star systems fetched concurrently, read from SYNC_WORKERS.
+// The requests still share the rate limit of the UEX host (UEX_RATE_LIMIT, UEX_RATE_BURST),
+// more workers only help as long as that budget isn't used up.
+func SyncWorkers() int {
+	if workers := viper.GetInt("SYNC_WORKERS"); workers > 0 {
+		return workers
+	}
+	return DefaultSyncWorkers
+}
+
+// fetchResult is the outcome of the fetch of a single star system.
+type fetchResult[T any] struct {
+	resp *uex.Response[T]
+	err  error
+}
+
+// fetchPerSystem calls fetch for every system with at most workers calls at a time.
+// The results are returned in the order of systems, whatever order the calls complete in.
+// Once ctx is done no new call is started, the results of the systems left out are empty:
+// callers must check ctx before using them.
+func fetchPerSystem[T any](
+	ctx context.Context,
+	systems []*core.Record,
+	workers int,
+	fetch func(ctx context.Context, system *core.Record) (*uex.Response[T], error),
+) []fetchResult[T] {
+	results := make([]fetchResult[T], len(systems))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(workers, len(systems)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				resp, err := fetch(ctx, systems[i])
+				results[i] = fetchResult[T]{resp: resp, err: err}
+			}
+		}()
+	}
+
+feed:
+	for i := range systems {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,118 @@
+package uex
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is the number of consecutive failed requests that opens the circuit.
+	DefaultBreakerThreshold = 5
+
+	// DefaultBreakerCooldown is how long the circuit stays open before a new request is attempted.
+	DefaultBreakerCooldown = time.Minute
+)
+
+// ErrCircuitOpen is returned without contacting UEX when too many consecutive
+// requests failed. Syncs should stop early when they see it, UEX is clearly down.
+var ErrCircuitOpen = errors.New("uex: circuit breaker is open, upstream looks unavailable")
+
+// hostBreakers holds one circuitBreaker per host and settings, so every Client talking to
+// the same UEX host with the same threshold and cooldown sees the same upstream health,
+// like hostLimiters. A Client configured otherwise gets its own breaker.
+var hostBreakers sync.Map // map[string]*circuitBreaker
+
+// breakerForHost returns the shared circuit breaker of the given host and settings, creating it on first use.
+func breakerForHost(host string, threshold int, cooldown time.Duration) *circuitBreaker {
+	key := fmt.Sprintf("%s|%d|%s", host, threshold, cooldown)
+	if b, ok := hostBreakers.Load(key); ok {
+		return b.(*circuitBreaker)
+	}
+
+	b, _ := hostBreakers.LoadOrStore(key, newCircuitBreaker(threshold, cooldown))
+	return b.(*circuitBreaker)
+}
+
+// circuitBreaker fails requests fast after a run of consecutive upstream failures.
+// Once the cooldown elapsed the circuit is half-open: a single probe request is
+// let through while the others keep failing fast. A successful probe closes
+// the circuit, a failed one reopens it right away.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{threshold: threshold, cooldown: cooldown}
+}
+
+// allow returns ErrCircuitOpen while the circuit is open, or half-open with a probe in flight.
+// Every allowed request must be followed by a call to success, failure or release.
+func (b *circuitBreaker) allow() error {
+	if b == nil || b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return nil
+	}
+
+	if b.probing || time.Now().Before(b.openUntil) {
+		return ErrCircuitOpen
+	}
+
+	b.probing = true
+	return nil
+}
+
+// success closes the circuit.
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.openUntil = time.Time{}
+	b.probing = false
+}
+
+// failure records an upstream failure and opens the circuit once the threshold is reached.
+func (b *circuitBreaker) failure() {
+	if b == nil || b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// release ends a request that tells nothing about the upstream health, e.g. a
+// cancelled one, so that another probe may be sent when the circuit is half-open.
+func (b *circuitBreaker) release() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
@@ -0,0 +1,155 @@
+package uex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := newCircuitBreaker(3, time.Hour)
+
+	for i := 0; i < 2; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("allow before the threshold: %v", err)
+		}
+		b.failure()
+	}
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow before the threshold: %v", err)
+	}
+	b.failure()
+
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen once the threshold is reached, got %v", err)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	b := newCircuitBreaker(2, time.Hour)
+
+	b.failure()
+	b.success()
+	b.failure()
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("failures are not consecutive, the circuit should be closed: %v", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsSingleProbe(t *testing.T) {
+	b := newCircuitBreaker(1, 10*time.Millisecond)
+	b.failure()
+
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen during the cooldown, got %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected the probe to be allowed after the cooldown: %v", err)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected a second request to fail fast while probing, got %v", err)
+	}
+
+	// A released probe lets another one through
+	b.release()
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected a new probe after release: %v", err)
+	}
+
+	// A failed probe reopens the circuit for a whole cooldown
+	b.failure()
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the failed probe to reopen the circuit, got %v", err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	// A successful probe closes the circuit
+	if err := b.allow(); err != nil {
+		t.Fatalf("expected the probe to be allowed after the cooldown: %v", err)
+	}
+	b.success()
+	for i := 0; i < 3; i++ {
+		if err := b.allow(); err != nil {
+			t.Fatalf("expected the circuit to be closed after a successful probe: %v", err)
+		}
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	var nilBreaker *circuitBreaker
+	disabled := newCircuitBreaker(0, time.Hour)
+
+	for _, b := range []*circuitBreaker{nilBreaker, disabled} {
+		for i := 0; i < 10; i++ {
+			b.failure()
+		}
+		if err := b.allow(); err != nil {
+			t.Errorf("a disabled breaker should never open: %v", err)
+		}
+	}
+}
+
+func TestBreakerIsSharedPerHost(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := Config{MaxAttempts: 1, BreakerThreshold: 2, BreakerCooldown: time.Hour}
+	first := newTestClient(t, srv, cfg)
+	second := newTestClient(t, srv, cfg)
+
+	if first.breaker != second.breaker {
+		t.Fatal("clients of the same host should share their breaker")
+	}
+
+	first.ListCommodities(context.Background())
+	first.ListCommodities(context.Background())
+
+	if _, err := second.ListCommodities(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the second client to fail fast, got %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 requests to reach the server, got %d", got)
+	}
+
+	// Another threshold gets its own breaker, still closed
+	third := newTestClient(t, srv, Config{MaxAttempts: 1, BreakerThreshold: 3, BreakerCooldown: time.Hour})
+	if third.breaker == first.breaker {
+		t.Fatal("clients with different breaker settings should not share their breaker")
+	}
+	if _, err := third.ListCommodities(context.Background()); errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the third client to contact the server, got %v", err)
+	}
+}
+
+func TestBreakerIgnoresCancelledRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{MaxAttempts: 1, BreakerThreshold: 1, BreakerCooldown: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.ListCommodities(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if err := client.breaker.allow(); err != nil {
+		t.Errorf("a cancelled request should not open the circuit: %v", err)
+	}
+}
@@ -0,0 +1,384 @@
+// Package uex provides a small typed client for the UEX Corp API
+// (https://uexcorp.space/api), which is the upstream source for commodities
+// and the star system hierarchy synced into PulsePoint.
+package uex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultTimeout is the per-request timeout used when Config.Timeout is zero.
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultUserAgent is sent with every request when Config.UserAgent is empty.
+	DefaultUserAgent = "PulsePoint/1.0"
+)
+
+// ErrMissingBaseURL is returned by NewClient when no base URL is configured.
+var ErrMissingBaseURL = errors.New("uex: missing base URL")
+
+// StatusError is returned when the UEX API answers with a non 200 status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Body       string
+
+	// RetryAfter is the delay requested by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("uex: unexpected status %d from %s", e.StatusCode, e.URL)
+}
+
+// Temporary reports whether the request may succeed when retried later
+// (429 Too Many Requests and 5xx server errors).
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+// Config holds the settings used to construct a Client.
+type Config struct {
+	// BaseURL is the API root, e.g. "https://uexcorp.space/api/2.0".
+	BaseURL string
+
+	// APIKey is sent as a Bearer token. It may be empty for public endpoints.
+	APIKey string
+
+	// Timeout bounds every single request. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	// UserAgent is sent with every request. Defaults to DefaultUserAgent.
+	UserAgent string
+
+	// HTTPClient overrides the underlying http.Client (useful for tests).
+	// When set, Timeout is ignored.
+	HTTPClient *http.Client
+
+	// MaxAttempts is the number of tries per request, including the first one.
+	// Defaults to DefaultMaxAttempts, 1 disables retries.
+	MaxAttempts int
+
+	// RetryBaseDelay and RetryMaxDelay bound the exponential backoff between attempts.
+	// They default to DefaultRetryBaseDelay and DefaultRetryMaxDelay.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// MaxRetryAfter is the longest Retry-After the client will wait for.
+	// Defaults to DefaultMaxRetryAfter.
+	MaxRetryAfter time.Duration
+
+	// RequestsPerSecond and Burst define the request budget shared by all
+	// clients talking to the same host with the same budget. They default to DefaultRequestsPerSecond
+	// and DefaultBurst, a negative RequestsPerSecond disables rate limiting.
+	RequestsPerSecond float64
+	Burst             int
+
+	// BreakerThreshold is the number of consecutive failed requests after which every client
+	// talking to the same host with the same settings stops contacting UEX for BreakerCooldown. They default to
+	// DefaultBreakerThreshold and DefaultBreakerCooldown, a negative threshold disables the breaker.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// Client is a typed UEX API client. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	userAgent  string
+	httpClient *http.Client
+	retry      retryPolicy
+	limiter    *rateLimiter
+	breaker    *circuitBreaker
+}
+
+// NewClient creates a new Client from the given Config.
+func NewClient(cfg Config) (*Client, error) {
+	baseURL := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if baseURL == "" {
+		return nil, ErrMissingBaseURL
+	}
+
+	parsedURL, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("uex: invalid base URL %q: %w", baseURL, err)
+	}
+
+	userAgent := cfg.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+
+	retry := retryPolicy{
+		maxAttempts:   withDefault(cfg.MaxAttempts, DefaultMaxAttempts),
+		baseDelay:     withDefault(cfg.RetryBaseDelay, DefaultRetryBaseDelay),
+		maxDelay:      withDefault(cfg.RetryMaxDelay, DefaultRetryMaxDelay),
+		maxRetryAfter: withDefault(cfg.MaxRetryAfter, DefaultMaxRetryAfter),
+	}
+
+	var limiter *rateLimiter
+	if cfg.RequestsPerSecond >= 0 {
+		limiter = limiterForHost(
+			parsedURL.Host,
+			withDefault(cfg.RequestsPerSecond, DefaultRequestsPerSecond),
+			withDefault(cfg.Burst, DefaultBurst),
+		)
+	}
+
+	var breaker *circuitBreaker
+	if cfg.BreakerThreshold >= 0 {
+		breaker = breakerForHost(
+			parsedURL.Host,
+			withDefault(cfg.BreakerThreshold, DefaultBreakerThreshold),
+			withDefault(cfg.BreakerCooldown, DefaultBreakerCooldown),
+		)
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		apiKey:     cfg.APIKey,
+		userAgent:  userAgent,
+		httpClient: httpClient,
+		retry:      retry,
+		limiter:    limiter,
+		breaker:    breaker,
+	}, nil
+}
+
+// withDefault returns def when v is the zero value.
+func withDefault[T comparable](v, def T) T {
+	var zero T
+	if v == zero {
+		return def
+	}
+	return v
+}
+
+// BaseURL returns the API root the client sends requests to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// ListCommodities fetches every commodity known to UEX.
+// Like every List method, rows that can't be decoded are skipped and reported in Invalid.
+func (c *Client) ListCommodities(ctx context.Context) (*CommodityResponse, error) {
+	var resp CommodityResponse
+	if err := c.get(ctx, "/commodities", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListStarSystems fetches every star system known to UEX.
+func (c *Client) ListStarSystems(ctx context.Context) (*StarSystemResponse, error) {
+	var resp StarSystemResponse
+	if err := c.get(ctx, "/star_systems", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListPlanets fetches the planets of the star system with the given UEX id.
+func (c *Client) ListPlanets(ctx context.Context, systemID ID) (*PlanetResponse, error) {
+	var resp PlanetResponse
+	if err := c.get(ctx, "/planets", systemQuery(systemID), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListMoons fetches the moons of the star system with the given UEX id.
+func (c *Client) ListMoons(ctx context.Context, systemID ID) (*MoonResponse, error) {
+	var resp MoonResponse
+	if err := c.get(ctx, "/moons", systemQuery(systemID), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListSpaceStations fetches the space stations of the star system with the given UEX id.
+func (c *Client) ListSpaceStations(ctx context.Context, systemID ID) (*SpaceStationResponse, error) {
+	var resp SpaceStationResponse
+	if err := c.get(ctx, "/space_stations", systemQuery(systemID), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListCities fetches the cities of the star system with the given UEX id.
+func (c *Client) ListCities(ctx context.Context, systemID ID) (*CityResponse, error) {
+	var resp CityResponse
+	if err := c.get(ctx, "/cities", systemQuery(systemID), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListOutposts fetches the surface outposts of the star system with the given UEX id.
+func (c *Client) ListOutposts(ctx context.Context, systemID ID) (*OutpostResponse, error) {
+	var resp OutpostResponse
+	if err := c.get(ctx, "/outposts", systemQuery(systemID), &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListTerminals fetches every commodity trade terminal known to UEX.
+func (c *Client) ListTerminals(ctx context.Context) (*TerminalResponse, error) {
+	var resp TerminalResponse
+	if err := c.get(ctx, "/terminals", url.Values{"type": []string{"commodity"}}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListCommodityPrices fetches the commodity prices of every terminal.
+func (c *Client) ListCommodityPrices(ctx context.Context) (*CommodityPriceResponse, error) {
+	var resp CommodityPriceResponse
+	if err := c.get(ctx, "/commodities_prices_all", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func systemQuery(systemID ID) url.Values {
+	return url.Values{"id_star_system": []string{systemID.String()}}
+}
+
+// get sends a GET request to the given API path and decodes the JSON body into out.
+// Temporary failures are retried with backoff, and every attempt waits for the
+// host's request budget. Requests fail fast with ErrCircuitOpen when UEX looks down.
+// Waiting, retrying and the request itself all stop as soon as ctx is done.
+func (c *Client) get(ctx context.Context, path string, query url.Values, out any) error {
+	endpoint := c.baseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+
+	err := c.getWithRetry(ctx, endpoint, out)
+	switch {
+	case err == nil:
+		c.breaker.success()
+	case ctx.Err() == nil && isRetryable(err):
+		c.breaker.failure()
+	default:
+		// A cancelled sync or a rejected request is not an upstream failure,
+		// don't count it against the breaker
+		c.breaker.release()
+	}
+
+	return err
+}
+
+// getWithRetry sends the request until it succeeds, fails for good or runs out of attempts.
+func (c *Client) getWithRetry(ctx context.Context, endpoint string, out any) error {
+	for attempt := 1; ; attempt++ {
+		if err := c.limiter.wait(ctx); err != nil {
+			return err
+		}
+
+		err := c.do(ctx, endpoint, out)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if !isRetryable(err) || attempt >= c.retry.maxAttempts {
+			return err
+		}
+
+		delay := c.retry.backoff(attempt)
+
+		// Honor the delay requested by UEX, unless it would stall the sync for too long
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			if statusErr.RetryAfter > c.retry.maxRetryAfter {
+				return err
+			}
+			delay = statusErr.RetryAfter
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// do sends a single GET request and decodes the JSON body into out.
+func (c *Client) do(ctx context.Context, endpoint string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("uex: failed to create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("uex: request to %s failed: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		// Keep a short excerpt of the body, UEX usually explains the failure there
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &StatusError{
+			URL:        endpoint,
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("uex: failed to decode response from %s: %w", endpoint, err)
+	}
+
+	return nil
+}
+
+// sleep pauses for d or until ctx is done, whichever happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,258 @@
+package uex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a client talking to srv, without rate limiting nor
+// circuit breaker and with short retry delays. cfg may override any setting.
+func newTestClient(t *testing.T, srv *httptest.Server, cfg Config) *Client {
+	t.Helper()
+
+	cfg.BaseURL = srv.URL
+	if cfg.RequestsPerSecond == 0 {
+		cfg.RequestsPerSecond = -1
+	}
+	if cfg.BreakerThreshold == 0 {
+		cfg.BreakerThreshold = -1
+	}
+	if cfg.RetryBaseDelay == 0 {
+		cfg.RetryBaseDelay = time.Millisecond
+	}
+
+	client, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestNewClientRequiresBaseURL(t *testing.T) {
+	if _, err := NewClient(Config{BaseURL: "  "}); !errors.Is(err, ErrMissingBaseURL) {
+		t.Fatalf("expected ErrMissingBaseURL, got %v", err)
+	}
+}
+
+func TestClientDecodesResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/planets" || r.URL.Query().Get("id_star_system") != "68" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("unexpected Authorization header %q", got)
+		}
+		if got := r.Header.Get("User-Agent"); got != DefaultUserAgent {
+			t.Errorf("unexpected User-Agent header %q", got)
+		}
+
+		w.Write([]byte(`{"status":"ok","data":[
+			{"id":1,"id_star_system":"68","name":"Hurston","code":"HUR"},
+			{"id":{"broken":true},"name":"Broken"},
+			{"id":"2","id_star_system":68,"name":"Crusader","code":"CRU"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{APIKey: "secret"})
+
+	resp, err := client.ListPlanets(context.Background(), 68)
+	if err != nil {
+		t.Fatalf("ListPlanets: %v", err)
+	}
+
+	if len(resp.Data) != 2 {
+		t.Fatalf("expected 2 planets, got %d", len(resp.Data))
+	}
+	if p := resp.Data[1]; p.UexID != 2 || p.StarSystemID != 68 || p.Name != "Crusader" {
+		t.Errorf("unexpected planet %+v", p)
+	}
+
+	if len(resp.Invalid) != 1 || resp.Invalid[0].Index != 1 || resp.Invalid[0].Name != "Broken" {
+		t.Errorf("unexpected invalid rows %+v", resp.Invalid)
+	}
+}
+
+func TestClientFailsOnMalformedBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html>maintenance</html>`))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{})
+
+	_, err := client.ListCommodities(context.Background())
+	if err == nil {
+		t.Fatal("expected a decode error")
+	}
+	if isRetryable(err) {
+		t.Errorf("decode errors should not be retried: %v", err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client := newTestClient(t, srv, Config{Timeout: 20 * time.Millisecond, MaxAttempts: 1})
+
+	_, err := client.ListCommodities(context.Background())
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) || !urlErr.Timeout() {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+	if !IsTemporary(err) {
+		t.Errorf("timeouts should be temporary")
+	}
+}
+
+func TestClientRetriesTemporaryFailures(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":1,"name":"Agricium"}]}`))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{MaxAttempts: 4})
+
+	resp, err := client.ListCommodities(context.Background())
+	if err != nil {
+		t.Fatalf("ListCommodities: %v", err)
+	}
+	if len(resp.Data) != 1 {
+		t.Errorf("expected 1 commodity, got %d", len(resp.Data))
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestClientGivesUpAfterMaxAttempts(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{MaxAttempts: 3})
+
+	_, err := client.ListCommodities(context.Background())
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected a 502 StatusError, got %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 attempts, got %d", got)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("unknown endpoint"))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{MaxAttempts: 4})
+
+	_, err := client.ListCommodities(context.Background())
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a 404 StatusError, got %v", err)
+	}
+	if statusErr.Body != "unknown endpoint" {
+		t.Errorf("unexpected body %q", statusErr.Body)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestClientHonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{MaxAttempts: 2})
+
+	start := time.Now()
+	if _, err := client.ListCommodities(context.Background()); err != nil {
+		t.Fatalf("ListCommodities: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected the retry to wait for Retry-After, it waited %s", elapsed)
+	}
+}
+
+func TestClientGivesUpOnLongRetryAfter(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{MaxAttempts: 4, MaxRetryAfter: time.Second})
+
+	_, err := client.ListCommodities(context.Background())
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.RetryAfter != time.Hour {
+		t.Fatalf("expected a StatusError asking to retry in an hour, got %v", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single attempt, got %d", got)
+	}
+}
+
+func TestClientStopsRetryingWhenCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(t, srv, Config{MaxAttempts: 10, RetryBaseDelay: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.ListCommodities(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the backoff ignored the cancellation, it took %s", elapsed)
+	}
+}
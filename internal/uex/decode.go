@@ -0,0 +1,213 @@
+package uex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The UEX API is not strict about its types: flags come as 0/1, booleans or
+// strings, ids and prices sometimes as strings, and any of them may be null.
+// The types below accept all of these, so a single odd value doesn't fail a whole sync.
+
+// ID is a UEX id. It accepts numbers and numeric strings, null decodes to 0.
+type ID int64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	n, err := parseInt(data)
+	if err != nil {
+		return fmt.Errorf("uex: invalid id %s", data)
+	}
+
+	*id = ID(n)
+	return nil
+}
+
+// String returns the id in base 10.
+func (id ID) String() string {
+	return strconv.FormatInt(int64(id), 10)
+}
+
+// Int is a UEX integer such as a status or a unix timestamp. It accepts numbers
+// and numeric strings, null and "" decode to 0.
+type Int int64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *Int) UnmarshalJSON(data []byte) error {
+	n, err := parseInt(data)
+	if err != nil {
+		return fmt.Errorf("uex: invalid integer %s", data)
+	}
+
+	*i = Int(n)
+	return nil
+}
+
+// parseInt parses a JSON integer, possibly quoted. Some endpoints send integral
+// values as floats, e.g. 12.0, they are accepted as well.
+func parseInt(data []byte) (int64, error) {
+	value, err := unquote(data)
+	if err != nil || value == "" {
+		return 0, err
+	}
+
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return n, nil
+	}
+
+	f, ferr := strconv.ParseFloat(value, 64)
+	if ferr != nil || f != float64(int64(f)) {
+		return 0, err
+	}
+	return int64(f), nil
+}
+
+// Flag is a UEX boolean. It accepts booleans, numbers (non zero is true) and
+// their string forms, null and "" decode to false.
+type Flag bool
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (f *Flag) UnmarshalJSON(data []byte) error {
+	value, err := unquote(data)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(value) {
+	case "", "0", "false", "no":
+		*f = false
+		return nil
+	case "1", "true", "yes":
+		*f = true
+		return nil
+	}
+
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("uex: invalid flag %s", data)
+	}
+
+	*f = n != 0
+	return nil
+}
+
+// Number is a UEX decimal value such as a price. It accepts numbers and
+// numeric strings, null and "" decode to 0.
+type Number float64
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *Number) UnmarshalJSON(data []byte) error {
+	value, err := unquote(data)
+	if err != nil || value == "" {
+		*n = 0
+		return err
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("uex: invalid number %s", data)
+	}
+
+	*n = Number(f)
+	return nil
+}
+
+// unquote returns the raw JSON scalar as a trimmed string: strings are unquoted
+// and null becomes "". Objects and arrays are rejected.
+func unquote(data []byte) (string, error) {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case len(data) == 0, bytes.Equal(data, []byte("null")):
+		return "", nil
+	case data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(s), nil
+	case data[0] == '{', data[0] == '[':
+		return "", fmt.Errorf("uex: unexpected value %s", data)
+	default:
+		return string(data), nil
+	}
+}
+
+// RecordError describes a row of a list response that could not be decoded.
+// The row is skipped, the rest of the response is still used.
+type RecordError struct {
+	// Index is the position of the row in the response.
+	Index int
+
+	// ID is the UEX id of the row, when it could be read at all.
+	ID ID
+
+	// Name is the name of the row, when it could be read at all.
+	Name string
+
+	Err error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("uex: invalid record %d (id %d, %q): %s", e.Index, e.ID, e.Name, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Response is the envelope returned by the list endpoints.
+// Every row is decoded on its own: the rows that fail are reported in Invalid
+// instead of failing the whole response.
+type Response[T any] struct {
+	Data    []T
+	Invalid []*RecordError
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *Response[T]) UnmarshalJSON(data []byte) error {
+	var envelope struct {
+		Data []json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+
+	r.Data = make([]T, 0, len(envelope.Data))
+	r.Invalid = nil
+
+	for i, raw := range envelope.Data {
+		var item T
+		if err := json.Unmarshal(raw, &item); err != nil {
+			r.Invalid = append(r.Invalid, newRecordError(i, raw, err))
+			continue
+		}
+		r.Data = append(r.Data, item)
+	}
+
+	return nil
+}
+
+// newRecordError builds the RecordError of a row, salvaging its id and name if possible.
+func newRecordError(index int, raw json.RawMessage, err error) *RecordError {
+	recordErr := &RecordError{Index: index, Err: err}
+
+	var identity map[string]json.RawMessage
+	if json.Unmarshal(raw, &identity) != nil {
+		return recordErr
+	}
+
+	var id ID
+	if id.UnmarshalJSON(identity["id"]) == nil {
+		recordErr.ID = id
+	}
+	if name, err := unquote(identity["name"]); err == nil {
+		recordErr.Name = name
+	}
+
+	return recordErr
+}
@@ -0,0 +1,205 @@
+package uex
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestIDUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ID
+		wantErr bool
+	}{
+		{`42`, 42, false},
+		{`"42"`, 42, false},
+		{`" 42 "`, 42, false},
+		{`42.0`, 42, false},
+		{`null`, 0, false},
+		{`""`, 0, false},
+		{`42.5`, 0, true},
+		{`"abc"`, 0, true},
+		{`{}`, 0, true},
+		{`[1]`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got ID
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Int
+		wantErr bool
+	}{
+		{`1700000000`, 1700000000, false},
+		{`"1700000000"`, 1700000000, false},
+		{`-3`, -3, false},
+		{`12.0`, 12, false},
+		{`null`, 0, false},
+		{`""`, 0, false},
+		{`"1.5"`, 0, true},
+		{`true`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Int
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Flag
+		wantErr bool
+	}{
+		{`true`, true, false},
+		{`false`, false, false},
+		{`1`, true, false},
+		{`0`, false, false},
+		{`2`, true, false},
+		{`"1"`, true, false},
+		{`"0"`, false, false},
+		{`"TRUE"`, true, false},
+		{`"no"`, false, false},
+		{`"yes"`, true, false},
+		{`""`, false, false},
+		{`null`, false, false},
+		{`"maybe"`, false, true},
+		{`[]`, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Flag
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %t, want %t", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNumberUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Number
+		wantErr bool
+	}{
+		{`12.5`, 12.5, false},
+		{`"12.5"`, 12.5, false},
+		{`7`, 7, false},
+		{`"1e3"`, 1000, false},
+		{`null`, 0, false},
+		{`""`, 0, false},
+		{`"n/a"`, 0, true},
+		{`{"price":1}`, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			var got Number
+			err := json.Unmarshal([]byte(tt.input), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Unmarshal(%s) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantNames   []string
+		wantInvalid []RecordError
+		wantErr     bool
+	}{
+		{
+			name:      "valid rows",
+			input:     `{"status":"ok","data":[{"id":1,"name":"Agricium"},{"id":"2","name":"Beryl","is_illegal":"1"}]}`,
+			wantNames: []string{"Agricium", "Beryl"},
+		},
+		{
+			name:        "invalid rows are skipped",
+			input:       `{"data":[{"id":1,"name":"Agricium"},{"id":"7","name":"Broken","price_buy":"n/a"},{"id":3,"name":"Corundum"}]}`,
+			wantNames:   []string{"Agricium", "Corundum"},
+			wantInvalid: []RecordError{{Index: 1, ID: 7, Name: "Broken"}},
+		},
+		{
+			name:        "unreadable identity",
+			input:       `{"data":["not an object",{"id":{"nested":1},"name":"Odd"}]}`,
+			wantInvalid: []RecordError{{Index: 0}, {Index: 1, Name: "Odd"}},
+		},
+		{
+			name:  "null data",
+			input: `{"status":"ok","data":null}`,
+		},
+		{
+			name:    "not an envelope",
+			input:   `[{"id":1}]`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp CommodityResponse
+			err := json.Unmarshal([]byte(tt.input), &resp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(resp.Data) != len(tt.wantNames) {
+				t.Fatalf("got %d rows, want %d", len(resp.Data), len(tt.wantNames))
+			}
+			for i, name := range tt.wantNames {
+				if resp.Data[i].Name != name {
+					t.Errorf("row %d is %q, want %q", i, resp.Data[i].Name, name)
+				}
+			}
+
+			if len(resp.Invalid) != len(tt.wantInvalid) {
+				t.Fatalf("got %d invalid rows, want %d", len(resp.Invalid), len(tt.wantInvalid))
+			}
+			for i, want := range tt.wantInvalid {
+				got := resp.Invalid[i]
+				if got.Index != want.Index || got.ID != want.ID || got.Name != want.Name {
+					t.Errorf("invalid row %d is %+v, want %+v", i, got, want)
+				}
+				if got.Err == nil {
+					t.Errorf("invalid row %d has no error", i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package uex
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRequestsPerSecond is the sustained request budget per UEX host.
+	DefaultRequestsPerSecond = 2.0
+
+	// DefaultBurst is the number of requests that may be sent back to back before throttling kicks in.
+	DefaultBurst = 5
+)
+
+// hostLimiters holds one rateLimiter per host and settings, so every Client talking to
+// the same UEX host with the same budget shares it. A Client configured with another
+// budget gets its own limiter rather than silently using the first one's.
+var hostLimiters sync.Map // map[string]*rateLimiter
+
+// limiterForHost returns the shared rate limiter of the given host and settings, creating it on first use.
+func limiterForHost(host string, perSecond float64, burst int) *rateLimiter {
+	key := fmt.Sprintf("%s|%g|%d", host, perSecond, burst)
+	if l, ok := hostLimiters.Load(key); ok {
+		return l.(*rateLimiter)
+	}
+
+	l, _ := hostLimiters.LoadOrStore(key, newRateLimiter(perSecond, burst))
+	return l.(*rateLimiter)
+}
+
+// rateLimiter is a simple token bucket.
+type rateLimiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+	}
+}
+
+// reserve takes a token from the bucket and returns how long the caller has to wait before using it.
+func (l *rateLimiter) reserve() time.Duration {
+	if l == nil || l.perSecond <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.perSecond * float64(time.Second))
+}
+
+// wait blocks until the caller is allowed to send a request or ctx is done.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	return sleep(ctx, l.reserve())
+}
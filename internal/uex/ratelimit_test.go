@@ -0,0 +1,85 @@
+package uex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBurstThenThrottles(t *testing.T) {
+	l := newRateLimiter(10, 3)
+
+	for i := 0; i < 3; i++ {
+		if d := l.reserve(); d != 0 {
+			t.Fatalf("request %d of the burst should not wait, got %s", i+1, d)
+		}
+	}
+
+	// The bucket is empty, the next token comes in 1/10s
+	d := l.reserve()
+	if d <= 50*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("expected a wait of about 100ms, got %s", d)
+	}
+
+	// Waiting callers queue up behind each other
+	if next := l.reserve(); next <= d {
+		t.Errorf("expected the next wait to be longer than %s, got %s", d, next)
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	l := newRateLimiter(100, 1)
+
+	l.reserve()
+	time.Sleep(30 * time.Millisecond)
+
+	if d := l.reserve(); d != 0 {
+		t.Errorf("expected the bucket to be refilled, got a wait of %s", d)
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	var nilLimiter *rateLimiter
+	disabled := newRateLimiter(0, 1)
+
+	for _, l := range []*rateLimiter{nilLimiter, disabled} {
+		for i := 0; i < 10; i++ {
+			if d := l.reserve(); d != 0 {
+				t.Fatalf("a disabled limiter should never wait, got %s", d)
+			}
+		}
+	}
+}
+
+func TestRateLimiterWaitStopsWithContext(t *testing.T) {
+	l := newRateLimiter(0.01, 1)
+	l.reserve()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestLimiterIsSharedPerHostAndSettings(t *testing.T) {
+	first := limiterForHost("limiter.test", 1, 1)
+	second := limiterForHost("limiter.test", 1, 1)
+	faster := limiterForHost("limiter.test", 50, 10)
+	other := limiterForHost("other.limiter.test", 1, 1)
+
+	if first != second {
+		t.Error("limiters of the same host and settings should be shared")
+	}
+	if first == faster {
+		t.Error("limiters with different settings should not be shared")
+	}
+	if faster.perSecond != 50 || faster.burst != 10 {
+		t.Errorf("unexpected settings %g/%g, want 50/10", faster.perSecond, faster.burst)
+	}
+	if first == other {
+		t.Error("limiters of different hosts should not be shared")
+	}
+}
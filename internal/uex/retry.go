@@ -0,0 +1,94 @@
+package uex
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the number of tries (including the first one) per request.
+	DefaultMaxAttempts = 4
+
+	// DefaultRetryBaseDelay is the delay before the first retry, doubled on every attempt.
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+
+	// DefaultRetryMaxDelay caps the exponential backoff between two attempts.
+	DefaultRetryMaxDelay = 30 * time.Second
+
+	// DefaultMaxRetryAfter is the longest Retry-After we are willing to wait for.
+	// When UEX asks for more than that the request fails instead of blocking the sync.
+	DefaultMaxRetryAfter = 2 * time.Minute
+)
+
+// retryPolicy describes how failed requests are retried.
+type retryPolicy struct {
+	maxAttempts   int
+	baseDelay     time.Duration
+	maxDelay      time.Duration
+	maxRetryAfter time.Duration
+}
+
+// backoff returns the delay before the given retry (1 for the first retry).
+// It uses exponential backoff with "full jitter", so concurrent clients don't retry in lockstep.
+func (p retryPolicy) backoff(retry int) time.Duration {
+	delay := p.baseDelay
+	for i := 1; i < retry && delay < p.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.maxDelay {
+		delay = p.maxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+
+	// Keep at least half of the delay so a retry never fires immediately
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// IsTemporary reports whether err means UEX is (temporarily) unavailable rather
+// than rejecting the request: network failures, 429, 5xx and an open circuit breaker.
+func IsTemporary(err error) bool {
+	return errors.Is(err, ErrCircuitOpen) || isRetryable(err)
+}
+
+// isRetryable reports whether a failed request is worth retrying.
+// Network failures, 429 Too Many Requests and 5xx responses are retried,
+// everything else (4xx, decode errors, ...) is returned right away.
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which may either
+// be a number of seconds or an HTTP date. It returns 0 when the header is missing or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if d := date.Sub(now); d > 0 {
+			return d
+		}
+	}
+
+	return 0
+}
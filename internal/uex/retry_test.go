@@ -0,0 +1,98 @@
+package uex
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"missing", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"zero seconds", "0", 0},
+		{"negative seconds", "-5", 0},
+		{"http date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"http date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"invalid", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"too many requests", &StatusError{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &StatusError{StatusCode: http.StatusInternalServerError}, true},
+		{"wrapped server error", fmt.Errorf("fetching: %w", &StatusError{StatusCode: http.StatusBadGateway}), true},
+		{"not found", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"network failure", &url.Error{Op: "Get", URL: "https://uex", Err: errors.New("connection refused")}, true},
+		{"decode failure", errors.New("uex: failed to decode response"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryable(tt.err); got != tt.want {
+				t.Errorf("isRetryable(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	policy := retryPolicy{baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	tests := []struct {
+		retry int
+		delay time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{20, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("retry %d", tt.retry), func(t *testing.T) {
+			seen := map[time.Duration]bool{}
+			for i := 0; i < 50; i++ {
+				got := policy.backoff(tt.retry)
+				if got < tt.delay/2 || got > tt.delay {
+					t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.retry, got, tt.delay/2, tt.delay)
+				}
+				seen[got] = true
+			}
+			if len(seen) < 2 {
+				t.Errorf("backoff(%d) always returned the same delay, expected jitter", tt.retry)
+			}
+		})
+	}
+}
+
+func TestBackoffWithoutDelay(t *testing.T) {
+	policy := retryPolicy{}
+	if got := policy.backoff(1); got != 0 {
+		t.Errorf("backoff without a base delay = %s, want 0", got)
+	}
+}
@@ -0,0 +1,162 @@
+package uex
+
+// CommodityResponse is the envelope returned by the /commodities endpoint.
+type CommodityResponse = Response[Commodity]
+
+type Commodity struct {
+	UexID           ID     `json:"id"`
+	Name            string `json:"name"`
+	Code            string `json:"code"`
+	Type            string `json:"kind"`
+	PriceBuy        Number `json:"price_buy"`
+	PriceSell       Number `json:"price_sell"`
+	IsIllegal       Flag   `json:"is_illegal"`
+	IsAvailableLive Flag   `json:"is_available_live"`
+	IsTemporary     Flag   `json:"is_temporary"`
+	IsSellable      Flag   `json:"is_sellable"`
+
+	// DateModified is the unix timestamp of the last update of the commodity, its prices included.
+	DateModified Int `json:"date_modified"`
+}
+
+// StarSystemResponse is the envelope returned by the /star_systems endpoint.
+type StarSystemResponse = Response[StarSystem]
+
+type StarSystem struct {
+	UexID        ID     `json:"id"`
+	Name         string `json:"name"`
+	Code         string `json:"code"`
+	Jurisdiction string `json:"jurisdiction"`
+	Faction      string `json:"faction"`
+	IsAvailable  Flag   `json:"is_available"`
+	IsVisible    Flag   `json:"is_visible"`
+}
+
+// PlanetResponse is the envelope returned by the /planets endpoint.
+type PlanetResponse = Response[Planet]
+
+type Planet struct {
+	UexID        ID     `json:"id"`
+	StarSystemID ID     `json:"id_star_system"`
+	Name         string `json:"name"`
+	Code         string `json:"code"`
+	Jurisdiction string `json:"jurisdiction"`
+	Faction      string `json:"faction"`
+}
+
+// MoonResponse is the envelope returned by the /moons endpoint.
+type MoonResponse = Response[Moon]
+
+type Moon struct {
+	UexID        ID     `json:"id"`
+	PlanetID     ID     `json:"id_planet"`
+	Name         string `json:"name"`
+	Code         string `json:"code"`
+	PlanetName   string `json:"planet_name"`
+	Jurisdiction string `json:"jurisdiction"`
+	Faction      string `json:"faction"`
+}
+
+// SpaceStationResponse is the envelope returned by the /space_stations endpoint.
+type SpaceStationResponse = Response[SpaceStation]
+
+type SpaceStation struct {
+	UexID          ID     `json:"id"`
+	StarSystemID   ID     `json:"id_star_system"`
+	PlanetID       ID     `json:"id_planet"`
+	MoonID         ID     `json:"id_moon"`
+	StarSystemName string `json:"star_system_name"`
+	PlanetName     string `json:"planet_name"`
+	MoonName       string `json:"moon_name"`
+	Name           string `json:"name"`
+	Code           string `json:"code"`
+	PadTypes       string `json:"pad_types"`
+	Jurisdiction   string `json:"jurisdiction"`
+	Faction        string `json:"faction"`
+	HasTerminal    Flag   `json:"has_trade_terminal"`
+	HasRefinery    Flag   `json:"has_refinery"`
+	Orbit          string `json:"orbit_name"`
+	IsLagrange     Flag   `json:"is_lagrange"`
+}
+
+// Location holds the fields shared by the landing zones UEX lists: cities and outposts.
+type Location struct {
+	UexID            ID     `json:"id"`
+	StarSystemID     ID     `json:"id_star_system"`
+	PlanetID         ID     `json:"id_planet"`
+	MoonID           ID     `json:"id_moon"`
+	Name             string `json:"name"`
+	Code             string `json:"code"`
+	PadTypes         string `json:"pad_types"`
+	Jurisdiction     string `json:"jurisdiction"`
+	Faction          string `json:"faction"`
+	IsAvailable      Flag   `json:"is_available"`
+	IsDecommissioned Flag   `json:"is_decommissioned"`
+	IsArmistice      Flag   `json:"is_armistice"`
+	IsLandable       Flag   `json:"is_landable"`
+	HasTerminal      Flag   `json:"has_trade_terminal"`
+	HasHabitation    Flag   `json:"has_habitation"`
+	HasRefinery      Flag   `json:"has_refinery"`
+	HasCargoCenter   Flag   `json:"has_cargo_center"`
+	HasClinic        Flag   `json:"has_clinic"`
+	HasRefuel        Flag   `json:"has_refuel"`
+	HasRepair        Flag   `json:"has_repair"`
+	HasLoadingDock   Flag   `json:"has_loading_dock"`
+	HasDockingPort   Flag   `json:"has_docking_port"`
+	HasFreightLift   Flag   `json:"has_freight_elevator"`
+}
+
+// CityResponse is the envelope returned by the /cities endpoint.
+type CityResponse = Response[City]
+
+// City is a landing zone city, e.g. Area18 or Lorville.
+type City struct {
+	Location
+}
+
+// OutpostResponse is the envelope returned by the /outposts endpoint.
+type OutpostResponse = Response[Outpost]
+
+// Outpost is a surface outpost, not to be confused with the outposts of the organizations.
+type Outpost struct {
+	Location
+	Nickname string `json:"nickname"`
+}
+
+// TerminalResponse is the envelope returned by the /terminals endpoint.
+type TerminalResponse = Response[Terminal]
+
+type Terminal struct {
+	UexID          ID     `json:"id"`
+	StarSystemID   ID     `json:"id_star_system"`
+	PlanetID       ID     `json:"id_planet"`
+	MoonID         ID     `json:"id_moon"`
+	SpaceStationID ID     `json:"id_space_station"`
+	CityID         ID     `json:"id_city"`
+	OutpostID      ID     `json:"id_outpost"`
+	Name           string `json:"name"`
+	Nickname       string `json:"nickname"`
+	Code           string `json:"code"`
+	Type           string `json:"type"`
+	IsAvailable    Flag   `json:"is_available"`
+}
+
+// CommodityPriceResponse is the envelope returned by the /commodities_prices_all endpoint.
+type CommodityPriceResponse = Response[CommodityPrice]
+
+// CommodityPrice is the price of a commodity at a single terminal.
+type CommodityPrice struct {
+	UexID        ID     `json:"id"`
+	CommodityID  ID     `json:"id_commodity"`
+	TerminalID   ID     `json:"id_terminal"`
+	PriceBuy     Number `json:"price_buy"`
+	PriceSell    Number `json:"price_sell"`
+	ScuBuy       Number `json:"scu_buy"`
+	ScuSellStock Number `json:"scu_sell_stock"`
+	ScuSell      Number `json:"scu_sell"`
+	StatusBuy    Int    `json:"status_buy"`
+	StatusSell   Int    `json:"status_sell"`
+
+	// DateModified is the unix timestamp of the last report of the price.
+	DateModified Int `json:"date_modified"`
+}
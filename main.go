@@ -2,14 +2,20 @@ package main
 
 import (
 	"log"
-	"net/http"
+	"log/slog"
+	"os"
+	"strings"
 
+	"pulsepoint/internal/commands"
 	"pulsepoint/internal/hooks"
+	"pulsepoint/internal/routes"
 	"pulsepoint/internal/tasks"
+	_ "pulsepoint/migrations"
 
 	"github.com/pocketbase/pocketbase"
 	"github.com/pocketbase/pocketbase/apis"
 	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/plugins/migratecmd"
 	"github.com/spf13/viper"
 )
 
@@ -27,27 +33,91 @@ func main() {
 	}
 	l.Info("Config file loaded successfully")
 
+	// Register the migrate command, the schema is versioned in the migrations package.
+	// Automigrate is only enabled while developing with "go run".
+	isGoRun := strings.HasPrefix(os.Args[0], os.TempDir())
+	migratecmd.MustRegister(app, app.RootCmd, migratecmd.Config{
+		Automigrate: isGoRun,
+	})
+
+	// The runner owns the lifetime of the sync tasks, they are cancelled when the app terminates
+	runner := tasks.NewRunner(app.App, viper.GetDuration("SYNC_TIMEOUT"))
+	app.OnTerminate().BindFunc(func(e *core.TerminateEvent) error {
+		l.Info("Application is terminating, stopping running tasks")
+		runner.Stop()
+		return e.Next()
+	})
+
+	// Log every record the syncs change, other features subscribe to the same hook
+	tasks.OnRecordChange().BindFunc(func(e *tasks.RecordChangeEvent) error {
+		l.Debug("Synced record changed",
+			"collection", e.Collection,
+			"record_id", e.RecordID,
+			"created", e.Created,
+			"changed_fields", len(e.Changes))
+		return e.Next()
+	})
+
+	// Keep a price history of the synced commodities, written in the sync transaction
+	tasks.OnRecordSave().BindFunc(hooks.RecordCommodityPriceHistory)
+
+	// Register the sync command, e.g. "sync commodities --dry-run"
+	app.RootCmd.AddCommand(commands.NewSyncCommand(app.App, runner))
+
 	// Bind the serve function to define HTTP routes
 	app.OnServe().BindFunc(func(se *core.ServeEvent) error {
 		l.Info("Setting up HTTP routes")
 
-		// Register the route for updating commodities (with Superuser authentication)
-		se.Router.POST("/api/pulsepoint/updateCommodities", func(e *core.RequestEvent) error {
-			l.Info("Received request to update commodities")
-			tasks.UpdateCommodities(app.App) // Call the UpdateCommodities task
-			l.Info("Commodities updated successfully")
-			return e.JSON(http.StatusOK, map[string]bool{"success": true})
-			// Superuser authentication is required here when deploying
-		}).Bind(apis.RequireSuperuserAuth())
+		// Register the route for updating commodities (with Superuser authentication).
+		// The task runs in the background, the response carries the job id to poll.
+		// With ?dryRun=true it reports the diff of what it would write instead.
+		se.Router.POST("/api/pulsepoint/updateCommodities", routes.StartTask(runner, tasks.TaskCommodities, tasks.UpdateCommodities)).
+			Bind(apis.RequireSuperuserAuth())
 
 		// Register the route for updating star systems (with Superuser authentication)
-		se.Router.POST("/api/pulsepoint/updateStarSystems", func(e *core.RequestEvent) error {
-			l.Info("Received request to update star systems")
-			tasks.UpdateStarSystems(app.App) // Call the UpdateStarSystems task
-			l.Info("Star systems updated successfully")
-			return e.JSON(http.StatusOK, map[string]bool{"success": true})
-			// Superuser authentication is required here when deploying
-		}).Bind(apis.RequireSuperuserAuth())
+		se.Router.POST("/api/pulsepoint/updateStarSystems", routes.StartTask(runner, tasks.TaskStarSystems, tasks.UpdateStarSystems)).
+			Bind(apis.RequireSuperuserAuth())
+
+		// Register the route for updating the terminals and their prices (with Superuser authentication)
+		se.Router.POST("/api/pulsepoint/updateTerminals", routes.StartTask(runner, tasks.TaskTerminals, tasks.UpdateTerminals)).
+			Bind(apis.RequireSuperuserAuth())
+
+		// Register the route reporting the progress and outcome of a job (with Superuser authentication)
+		se.Router.GET("/api/pulsepoint/jobs/{id}", routes.GetJob(runner)).
+			Bind(apis.RequireSuperuserAuth())
+
+		// Register the route for aborting a running task (with Superuser authentication)
+		se.Router.POST("/api/pulsepoint/tasks/{name}/cancel", routes.CancelTask(runner)).
+			Bind(apis.RequireSuperuserAuth())
+
+		// Register the route returning the price history of a commodity (with user authentication)
+		se.Router.GET("/api/pulsepoint/commodities/{id}/priceHistory", routes.GetCommodityPriceHistory()).
+			Bind(apis.RequireAuth())
+
+		// Register the route finding the most profitable trade routes (with user authentication)
+		se.Router.GET("/api/pulsepoint/routes", routes.FindTradeRoutes()).
+			Bind(apis.RequireAuth())
+
+		// Register the route filling the missing outpost commodities of the outposts tracking all commodities (with Superuser authentication)
+		se.Router.POST("/api/pulsepoint/outpostCommodities/repair", routes.RepairOutpostCommodities()).
+			Bind(apis.RequireSuperuserAuth())
+
+		// Register the route listing the outposts and their stock by location (with user authentication)
+		se.Router.GET("/api/pulsepoint/outposts", routes.ListOutpostsByLocation()).
+			Bind(apis.RequireAuth())
+
+		// Register the route listing the stock of an outpost, tracked commodities included (with user authentication)
+		se.Router.GET("/api/pulsepoint/outposts/{id}/stock", routes.GetOutpostStock()).
+			Bind(apis.RequireAuth())
+
+		// Register the route changing the stock of an outpost (with user authentication)
+		se.Router.POST("/api/pulsepoint/outposts/{id}/inventory/adjust", routes.AdjustInventory()).
+			Bind(apis.RequireAuth())
+
+		// Register the route recomputing the inventory amounts from the ledger (with Superuser authentication).
+		// With ?dryRun=true it only reports the drifted amounts.
+		se.Router.POST("/api/pulsepoint/reconcileInventory", routes.StartTask(runner, tasks.TaskInventory, tasks.ReconcileInventory)).
+			Bind(apis.RequireSuperuserAuth())
 
 		return se.Next()
 	})
@@ -56,31 +126,99 @@ func main() {
 	l.Info("Scheduling cron jobs")
 	app.Cron().MustAdd("updatingCommodities", "0 */6 * * *", func() {
 		l.Info("Running cron job to update commodities")
-		tasks.UpdateCommodities(app.App)
-		l.Info("Commodities update completed by cron job")
+		run, err := runner.Run(tasks.TaskCommodities, tasks.TriggerCron, tasks.UpdateCommodities)
+		logCronRun(l, "Commodities update", run, err)
+	})
+	// Terminal prices link to the commodities, so they are refreshed once the commodities are
+	app.Cron().MustAdd("updatingTerminals", "15 */6 * * *", func() {
+		l.Info("Running cron job to update terminals")
+		run, err := runner.Run(tasks.TaskTerminals, tasks.TriggerCron, tasks.UpdateTerminals)
+		logCronRun(l, "Terminals update", run, err)
+	})
+	// The inventory drift is only reported, an admin corrects it through the reconcileInventory route
+	app.Cron().MustAdd("checkingInventory", "0 3 * * 1", func() {
+		l.Info("Running cron job to check the inventory against the ledger")
+		run, err := runner.DryRun(tasks.TaskInventory, tasks.TriggerCron, tasks.ReconcileInventory)
+		if !logCronRun(l, "Inventory check", run, err) {
+			return
+		}
+
+		// The drifted rows are in the diff stored with the run, see GET /api/pulsepoint/jobs/{id}
+		if drifted := run.Stats()["outpost_commodities"].Updated; drifted > 0 {
+			l.Warn("Inventory drifted from the ledger", "job", run.ID(), "drifted", drifted)
+		}
 	})
 	app.Cron().MustAdd("updatingStarSystems", "0 12 1 */1 *", func() {
 		l.Info("Running cron job to update star systems")
-		tasks.UpdateStarSystems(app.App)
-		l.Info("Star systems update completed by cron job")
+		run, err := runner.Run(tasks.TaskStarSystems, tasks.TriggerCron, tasks.UpdateStarSystems)
+		logCronRun(l, "Star systems update", run, err)
 	})
 
-	// Hook for after a new outpost record is successfully created
-	app.OnRecordAfterCreateSuccess("outposts").BindFunc(func(e *core.RecordEvent) error {
-		l.Info("New outpost record created, triggering outpost commodities hook")
-		hooks.CreateOutpostCommodities(e)
-		l.Info("Outpost commodities created successfully")
-		return e.Next()
-	})
+	// Hook validating the location of an outpost whenever it is created or updated
+	app.OnRecordValidate("outposts").BindFunc(hooks.ValidateOutpostLocation)
 
-	// Hook for after an outpost_commodities record is updated
-	app.OnRecordUpdateExecute("outpost_commodities").BindFunc(func(e *core.RecordEvent) error {
-		l.Info("Outpost_commodities record updated, triggering commodity changes hook")
-		hooks.CreateCommodityChanges(e)
-		l.Info("Commodity changes created successfully")
-		return e.Next()
+	// Hook validating the commodities an outpost tracks whenever it is created or updated
+	app.OnRecordValidate("outposts").BindFunc(hooks.ValidateOutpostTracking)
+
+	// Hook validating the outpost_commodities rows created on demand
+	app.OnRecordValidate("outpost_commodities").BindFunc(hooks.ValidateOutpostCommodity)
+
+	// Hooks rejecting the amounts written through the records API, superusers included.
+	// Stock changes only go through the inventory adjust endpoint, so all of them are in the ledger.
+	app.OnRecordCreateRequest("outpost_commodities").BindFunc(hooks.BlockDirectAmountWrites)
+	app.OnRecordUpdateRequest("outpost_commodities").BindFunc(hooks.BlockDirectAmountWrites)
+
+	// Hook for when a new commodity record is created, e.g. by the commodities sync.
+	// Every outpost tracking all commodities gets an outpost_commodities row for it in the same transaction.
+	app.OnRecordCreateExecute("commodities").BindFunc(func(e *core.RecordEvent) error {
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+			if err := e.Next(); err != nil {
+				return err
+			}
+
+			l.Info("New commodity record created, backfilling outpost commodities")
+			if err := hooks.BackfillOutpostCommodities(e); err != nil {
+				l.Error("Failed to backfill outpost commodities", "error", err.Error())
+				return err
+			}
+			return nil
+		})
 	})
 
+	// Hooks for when an outpost is created or updated.
+	// An outpost created or switched to tracking all commodities gets a row for every active commodity in the same transaction.
+	backfillOutpost := func(e *core.RecordEvent) error {
+		return e.App.RunInTransaction(func(txApp core.App) error {
+			e.App = txApp
+
+			if err := hooks.BackfillOutpost(e); err != nil {
+				l.Error("Failed to backfill the outpost commodities of the outpost", "error", err.Error())
+				return err
+			}
+			return nil
+		})
+	}
+	app.OnRecordCreateExecute("outposts").BindFunc(backfillOutpost)
+	app.OnRecordUpdateExecute("outposts").BindFunc(backfillOutpost)
+
+	// Hooks for when an outpost or a commodity is deleted.
+	// Their inventory is archived (or the deletion blocked, see hooks.DeletePolicy) in the same transaction.
+	for _, collection := range []string{"outposts", "commodities"} {
+		app.OnRecordDeleteExecute(collection).BindFunc(func(e *core.RecordEvent) error {
+			return e.App.RunInTransaction(func(txApp core.App) error {
+				e.App = txApp
+
+				l.Info("Record deleted, archiving its inventory", "collection", e.Record.Collection().Name)
+				if err := hooks.ArchiveInventory(e); err != nil {
+					l.Error("Failed to archive the inventory", "error", err.Error())
+					return err
+				}
+				return e.Next()
+			})
+		})
+	}
+
 	// Start the application and handle errors
 	l.Info("Starting PocketBase application")
 	if err := app.Start(); err != nil {
@@ -89,3 +227,20 @@ func main() {
 	}
 	l.Info("PocketBase application started successfully")
 }
+
+// logCronRun logs the outcome of a task run triggered by a cron job,
+// or why it was not started. It reports whether the task ran.
+func logCronRun(l *slog.Logger, task string, run *tasks.SyncRun, err error) bool {
+	if err != nil {
+		l.Warn(task+" by cron job was not started", "error", err.Error())
+		return false
+	}
+
+	if err := run.Err(); err != nil {
+		l.Error(task+" by cron job did not complete", "job", run.ID(), "status", run.Status(), "error", err.Error())
+		return true
+	}
+
+	l.Info(task+" completed by cron job", "job", run.ID(), "status", run.Status())
+	return true
+}
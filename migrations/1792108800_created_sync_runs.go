@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Creates the sync_runs collection, one record per execution of a sync task.
+// It is only readable by superusers, the records are written by the tasks runner.
+func init() {
+	m.Register(func(app core.App) error {
+		collection := core.NewBaseCollection("sync_runs")
+
+		collection.Fields.Add(
+			&core.TextField{Name: "task", Required: true, Max: 100},
+			&core.SelectField{Name: "trigger", Required: true, MaxSelect: 1, Values: []string{"cron", "manual"}},
+			&core.SelectField{Name: "status", Required: true, MaxSelect: 1, Values: []string{"running", "success", "failed", "cancelled"}},
+			&core.DateField{Name: "started_at", Required: true},
+			&core.DateField{Name: "finished_at"},
+			&core.JSONField{Name: "stats"},
+			&core.TextField{Name: "error"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+
+		collection.AddIndex("idx_sync_runs_task_started_at", false, "task, started_at", "")
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		return app.Delete(collection)
+	})
+}
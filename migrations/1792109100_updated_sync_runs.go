@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Adds the machine readable error_code (see the errs package) to sync_runs.
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.TextField{Name: "error_code", Max: 50})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("error_code")
+
+		return app.Save(collection)
+	})
+}
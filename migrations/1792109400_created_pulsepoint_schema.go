@@ -0,0 +1,241 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// API rules shared by the collections below.
+var (
+	// authenticatedRule lets any signed in user read the game reference data.
+	authenticatedRule = types.Pointer(`@request.auth.id != ""`)
+
+	// organizationMemberRule restricts records to the members of the organization they belong to.
+	organizationMemberRule = types.Pointer(`@request.auth.id != "" && organization.members.id ?= @request.auth.id`)
+)
+
+// pulsepointCollections are the collections of the migration below, in creation order.
+var pulsepointCollections = []string{
+	"organizations",
+	"commodities",
+	"star_systems",
+	"planets",
+	"moons",
+	"space_stations",
+	"outposts",
+	"outpost_commodities",
+	"outpost_commodity_changes",
+}
+
+// pulsepointSchemaParam is the _params row listing the collections created by the migration below.
+const pulsepointSchemaParam = "pulsepoint_schema_created_collections"
+
+// Creates the whole PulsePoint schema: the organizations, the game reference data
+// synced from UEX and the outposts with their inventory ledger.
+//
+// Databases created before the schema was versioned already have (some of) these
+// collections: they only get the fields and indexes they miss (see ensureCollection),
+// and the down migration only deletes the collections created here.
+func init() {
+	m.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		// Remember which collections don't exist yet, the down migration only deletes those
+		var created []string
+		for _, name := range pulsepointCollections {
+			if _, err := app.FindCollectionByNameOrId(name); err != nil {
+				created = append(created, name)
+			}
+		}
+
+		// Organizations
+		organizations := core.NewBaseCollection("organizations")
+		organizations.ListRule = types.Pointer(`@request.auth.id != "" && members.id ?= @request.auth.id`)
+		organizations.ViewRule = organizations.ListRule
+		organizations.CreateRule = authenticatedRule
+		organizations.UpdateRule = organizations.ListRule
+		organizations.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.RelationField{Name: "members", CollectionId: users.Id, MaxSelect: 999},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		organizations.AddIndex("idx_organizations_name", true, "name", "")
+		if organizations, err = ensureCollection(app, organizations); err != nil {
+			return err
+		}
+
+		// Commodities, synced by tasks.UpdateCommodities
+		commodities := core.NewBaseCollection("commodities")
+		commodities.ListRule = authenticatedRule
+		commodities.ViewRule = authenticatedRule
+		commodities.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.TextField{Name: "code", Required: true, Max: 20},
+			&core.TextField{Name: "type", Max: 50},
+			&core.NumberField{Name: "price_buy"},
+			&core.NumberField{Name: "price_sell"},
+			&core.BoolField{Name: "is_illegal"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		commodities.AddIndex("idx_commodities_code", true, "code", "")
+		if commodities, err = ensureCollection(app, commodities); err != nil {
+			return err
+		}
+
+		// Star systems, planets, moons and space stations, synced by tasks.UpdateStarSystems
+		starSystems := core.NewBaseCollection("star_systems")
+		starSystems.ListRule = authenticatedRule
+		starSystems.ViewRule = authenticatedRule
+		starSystems.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.TextField{Name: "code", Required: true, Max: 20},
+			&core.TextField{Name: "jurisdiction", Max: 100},
+			&core.TextField{Name: "faction", Max: 100},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		starSystems.AddIndex("idx_star_systems_code", true, "code", "")
+		starSystems.AddIndex("idx_star_systems_name", false, "name", "")
+		if starSystems, err = ensureCollection(app, starSystems); err != nil {
+			return err
+		}
+
+		planets := core.NewBaseCollection("planets")
+		planets.ListRule = authenticatedRule
+		planets.ViewRule = authenticatedRule
+		planets.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.TextField{Name: "code", Required: true, Max: 20},
+			&core.RelationField{Name: "star_system", CollectionId: starSystems.Id, MaxSelect: 1},
+			&core.TextField{Name: "jurisdiction", Max: 100},
+			&core.TextField{Name: "faction", Max: 100},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		planets.AddIndex("idx_planets_code", true, "code", "")
+		planets.AddIndex("idx_planets_name", false, "name", "")
+		if planets, err = ensureCollection(app, planets); err != nil {
+			return err
+		}
+
+		moons := core.NewBaseCollection("moons")
+		moons.ListRule = authenticatedRule
+		moons.ViewRule = authenticatedRule
+		moons.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.TextField{Name: "code", Required: true, Max: 20},
+			&core.RelationField{Name: "planet", Required: true, CollectionId: planets.Id, MaxSelect: 1},
+			&core.TextField{Name: "jurisdiction", Max: 100},
+			&core.TextField{Name: "faction", Max: 100},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		moons.AddIndex("idx_moons_code", true, "code", "")
+		moons.AddIndex("idx_moons_name", false, "name", "")
+		if moons, err = ensureCollection(app, moons); err != nil {
+			return err
+		}
+
+		spaceStations := core.NewBaseCollection("space_stations")
+		spaceStations.ListRule = authenticatedRule
+		spaceStations.ViewRule = authenticatedRule
+		spaceStations.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.RelationField{Name: "star_system", Required: true, CollectionId: starSystems.Id, MaxSelect: 1},
+			&core.RelationField{Name: "planet", CollectionId: planets.Id, MaxSelect: 1},
+			&core.RelationField{Name: "moon", CollectionId: moons.Id, MaxSelect: 1},
+			&core.TextField{Name: "pad_types", Max: 50},
+			&core.TextField{Name: "jurisdiction", Max: 100},
+			&core.TextField{Name: "faction", Max: 100},
+			&core.BoolField{Name: "has_trade_terminal"},
+			&core.BoolField{Name: "has_refinery"},
+			&core.TextField{Name: "orbit", Max: 100},
+			&core.BoolField{Name: "is_lagrange"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		spaceStations.AddIndex("idx_space_stations_name", true, "name", "")
+		if _, err = ensureCollection(app, spaceStations); err != nil {
+			return err
+		}
+
+		// Outposts of the organizations and their inventory
+		outposts := core.NewBaseCollection("outposts")
+		outposts.ListRule = organizationMemberRule
+		outposts.ViewRule = organizationMemberRule
+		outposts.CreateRule = organizationMemberRule
+		outposts.UpdateRule = organizationMemberRule
+		outposts.DeleteRule = organizationMemberRule
+		outposts.Fields.Add(
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.RelationField{Name: "organization", Required: true, CollectionId: organizations.Id, MaxSelect: 1},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		outposts.AddIndex("idx_outposts_organization", false, "organization", "")
+		if outposts, err = ensureCollection(app, outposts); err != nil {
+			return err
+		}
+
+		// One row per (outpost, commodity), created upfront by hooks.BackfillOutpost and
+		// hooks.BackfillOutpostCommodities, or on demand by hooks.AdjustInventory
+		outpostCommodities := core.NewBaseCollection("outpost_commodities")
+		outpostCommodities.ListRule = organizationMemberRule
+		outpostCommodities.ViewRule = organizationMemberRule
+		outpostCommodities.UpdateRule = organizationMemberRule
+		outpostCommodities.Fields.Add(
+			&core.RelationField{Name: "organization", Required: true, CollectionId: organizations.Id, MaxSelect: 1},
+			&core.RelationField{Name: "outpost", Required: true, CollectionId: outposts.Id, MaxSelect: 1},
+			&core.RelationField{Name: "commodity", Required: true, CollectionId: commodities.Id, MaxSelect: 1},
+			&core.NumberField{Name: "amount"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		outpostCommodities.AddIndex("idx_outpost_commodities_outpost_commodity", true, "outpost, commodity", "")
+		if outpostCommodities, err = ensureCollection(app, outpostCommodities); err != nil {
+			return err
+		}
+
+		// Append only ledger of the amount changes, written by hooks.RecordCommodityChange
+		outpostCommodityChanges := core.NewBaseCollection("outpost_commodity_changes")
+		outpostCommodityChanges.ListRule = organizationMemberRule
+		outpostCommodityChanges.ViewRule = organizationMemberRule
+		outpostCommodityChanges.Fields.Add(
+			&core.RelationField{Name: "organization", Required: true, CollectionId: organizations.Id, MaxSelect: 1},
+			&core.RelationField{Name: "outpost", Required: true, CollectionId: outposts.Id, MaxSelect: 1},
+			&core.RelationField{Name: "outpost_commodity", Required: true, CollectionId: outpostCommodities.Id, MaxSelect: 1},
+			&core.RelationField{Name: "commodity", Required: true, CollectionId: commodities.Id, MaxSelect: 1},
+			&core.NumberField{Name: "change_amount"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		outpostCommodityChanges.AddIndex("idx_outpost_commodity_changes_outpost_commodity", false, "outpost_commodity, created", "")
+		if _, err = ensureCollection(app, outpostCommodityChanges); err != nil {
+			return err
+		}
+
+		return saveCreatedCollections(app, pulsepointSchemaParam, created)
+	}, func(app core.App) error {
+		created, err := findCreatedCollections(app, pulsepointSchemaParam)
+		if err != nil {
+			return err
+		}
+
+		// Reverse order, so no collection is deleted while another one still references it
+		names := make([]string, 0, len(created))
+		for i := len(created) - 1; i >= 0; i-- {
+			names = append(names, created[i])
+		}
+		if err := deleteCollections(app, names...); err != nil {
+			return err
+		}
+
+		return forgetCreatedCollections(app, pulsepointSchemaParam)
+	})
+}
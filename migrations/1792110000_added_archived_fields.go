@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// syncedCollections are the collections mirrored from UEX, whose records get
+// archived by the sync when they disappear upstream.
+var syncedCollections = []string{"commodities", "star_systems", "planets", "moons", "space_stations"}
+
+// Adds the archived flag and timestamp to the synced collections and hides
+// archived records from the default listings. Archived records can still be
+// viewed by id (so relations keep resolving) and listed with an explicit filter by superusers.
+func init() {
+	m.Register(func(app core.App) error {
+		for _, name := range syncedCollections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			collection.Fields.Add(
+				&core.BoolField{Name: "archived"},
+				&core.DateField{Name: "archived_at"},
+			)
+			collection.AddIndex("idx_"+name+"_archived", false, "archived", "")
+			collection.ListRule = types.Pointer(`@request.auth.id != "" && archived = false`)
+
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		for _, name := range syncedCollections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			collection.RemoveIndex("idx_" + name + "_archived")
+			collection.Fields.RemoveByName("archived")
+			collection.Fields.RemoveByName("archived_at")
+			collection.ListRule = authenticatedRule
+
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Adds the dry_run flag to sync_runs, dry runs are recorded but never commit anything.
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.BoolField{Name: "dry_run"})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("dry_run")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Adds the uex_id field to the synced collections. The UEX id is the stable
+// identity of a record, codes and names can be renamed upstream.
+//
+// Existing records start with uex_id = 0 and are matched once more by their
+// code (or name) by the next sync, which backfills their id. The unique index
+// therefore only covers the records whose id is known.
+func init() {
+	m.Register(func(app core.App) error {
+		for _, name := range syncedCollections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			collection.Fields.Add(&core.NumberField{Name: "uex_id", OnlyInt: true})
+			collection.AddIndex("idx_"+name+"_uex_id", true, "uex_id", "uex_id != 0")
+
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		for _, name := range syncedCollections {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			collection.RemoveIndex("idx_" + name + "_uex_id")
+			collection.Fields.RemoveByName("uex_id")
+
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
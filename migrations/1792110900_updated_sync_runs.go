@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Adds the outcome of every stage of a staged task (e.g. the star systems sync) to sync_runs.
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.JSONField{Name: "stages"})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("stages")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Creates commodity_price_history, an append only log of the synced commodity prices,
+// written by hooks.RecordCommodityPriceHistory whenever a price changes.
+func init() {
+	m.Register(func(app core.App) error {
+		commodities, err := app.FindCollectionByNameOrId("commodities")
+		if err != nil {
+			return err
+		}
+
+		history := core.NewBaseCollection("commodity_price_history")
+		history.ListRule = authenticatedRule
+		history.ViewRule = authenticatedRule
+		history.Fields.Add(
+			&core.RelationField{Name: "commodity", Required: true, CollectionId: commodities.Id, MaxSelect: 1, CascadeDelete: true},
+			&core.NumberField{Name: "price_buy"},
+			&core.NumberField{Name: "price_sell"},
+			&core.TextField{Name: "source", Max: 50},
+			&core.DateField{Name: "observed_at", Required: true},
+			&core.AutodateField{Name: "created", OnCreate: true},
+		)
+		history.AddIndex("idx_commodity_price_history_commodity_observed_at", false, "commodity, observed_at", "")
+
+		_, err = ensureCollection(app, history)
+		return err
+	}, func(app core.App) error {
+		return deleteCollections(app, "commodity_price_history")
+	})
+}
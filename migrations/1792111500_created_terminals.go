@@ -0,0 +1,79 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Creates the UEX trade terminals and the commodity prices per terminal,
+// synced by tasks.UpdateTerminals. Like the other synced collections they
+// are keyed by their UEX id and archived when they disappear upstream.
+func init() {
+	m.Register(func(app core.App) error {
+		collectionIds := map[string]string{}
+		for _, name := range []string{"commodities", "star_systems", "planets", "moons", "space_stations"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			collectionIds[name] = collection.Id
+		}
+
+		activeRule := types.Pointer(`@request.auth.id != "" && archived = false`)
+
+		terminals := core.NewBaseCollection("terminals")
+		terminals.ListRule = activeRule
+		terminals.ViewRule = authenticatedRule
+		terminals.Fields.Add(
+			&core.NumberField{Name: "uex_id", OnlyInt: true},
+			&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+			&core.TextField{Name: "nickname", Max: 100},
+			&core.TextField{Name: "code", Max: 20},
+			&core.TextField{Name: "type", Max: 50},
+			&core.RelationField{Name: "star_system", CollectionId: collectionIds["star_systems"], MaxSelect: 1},
+			&core.RelationField{Name: "planet", CollectionId: collectionIds["planets"], MaxSelect: 1},
+			&core.RelationField{Name: "moon", CollectionId: collectionIds["moons"], MaxSelect: 1},
+			&core.RelationField{Name: "space_station", CollectionId: collectionIds["space_stations"], MaxSelect: 1},
+			&core.BoolField{Name: "archived"},
+			&core.DateField{Name: "archived_at"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		terminals.AddIndex("idx_terminals_uex_id", true, "uex_id", "uex_id != 0")
+		terminals.AddIndex("idx_terminals_archived", false, "archived", "")
+		terminals, err := ensureCollection(app, terminals)
+		if err != nil {
+			return err
+		}
+
+		prices := core.NewBaseCollection("terminal_commodity_prices")
+		prices.ListRule = activeRule
+		prices.ViewRule = authenticatedRule
+		prices.Fields.Add(
+			&core.NumberField{Name: "uex_id", OnlyInt: true},
+			&core.RelationField{Name: "terminal", Required: true, CollectionId: terminals.Id, MaxSelect: 1, CascadeDelete: true},
+			&core.RelationField{Name: "commodity", Required: true, CollectionId: collectionIds["commodities"], MaxSelect: 1, CascadeDelete: true},
+			&core.NumberField{Name: "price_buy"},
+			&core.NumberField{Name: "price_sell"},
+			&core.NumberField{Name: "scu_buy"},
+			&core.NumberField{Name: "scu_sell_stock"},
+			&core.NumberField{Name: "scu_sell"},
+			&core.NumberField{Name: "status_buy", OnlyInt: true},
+			&core.NumberField{Name: "status_sell", OnlyInt: true},
+			&core.DateField{Name: "reported_at"},
+			&core.BoolField{Name: "archived"},
+			&core.DateField{Name: "archived_at"},
+			&core.AutodateField{Name: "created", OnCreate: true},
+			&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+		)
+		prices.AddIndex("idx_terminal_commodity_prices_uex_id", true, "uex_id", "uex_id != 0")
+		prices.AddIndex("idx_terminal_commodity_prices_terminal_commodity", true, "terminal, commodity", "")
+		prices.AddIndex("idx_terminal_commodity_prices_commodity", false, "commodity", "")
+		prices.AddIndex("idx_terminal_commodity_prices_archived", false, "archived", "")
+		_, err = ensureCollection(app, prices)
+		return err
+	}, func(app core.App) error {
+		return deleteCollections(app, "terminal_commodity_prices", "terminals")
+	})
+}
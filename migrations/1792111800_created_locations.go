@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Creates the landing zone cities and the surface outposts synced by
+// tasks.UpdateStarSystems, next to the space stations, and links the terminals to them.
+// Surface outposts get their own collection, outposts being the outposts of the organizations.
+func init() {
+	m.Register(func(app core.App) error {
+		collectionIds := map[string]string{}
+		for _, name := range []string{"star_systems", "planets", "moons"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			collectionIds[name] = collection.Id
+		}
+
+		activeRule := types.Pointer(`@request.auth.id != "" && archived = false`)
+
+		locationIds := map[string]string{}
+		for _, name := range []string{"cities", "surface_outposts"} {
+			locations := core.NewBaseCollection(name)
+			locations.ListRule = activeRule
+			locations.ViewRule = authenticatedRule
+			locations.Fields.Add(
+				&core.NumberField{Name: "uex_id", OnlyInt: true},
+				&core.TextField{Name: "name", Required: true, Max: 100, Presentable: true},
+				&core.TextField{Name: "code", Max: 20},
+				&core.TextField{Name: "nickname", Max: 100},
+				&core.RelationField{Name: "star_system", Required: true, CollectionId: collectionIds["star_systems"], MaxSelect: 1},
+				&core.RelationField{Name: "planet", CollectionId: collectionIds["planets"], MaxSelect: 1},
+				&core.RelationField{Name: "moon", CollectionId: collectionIds["moons"], MaxSelect: 1},
+				&core.TextField{Name: "pad_types", Max: 50},
+				&core.TextField{Name: "jurisdiction", Max: 100},
+				&core.TextField{Name: "faction", Max: 100},
+				&core.BoolField{Name: "is_armistice"},
+				&core.BoolField{Name: "is_landable"},
+				&core.BoolField{Name: "has_trade_terminal"},
+				&core.BoolField{Name: "has_habitation"},
+				&core.BoolField{Name: "has_refinery"},
+				&core.BoolField{Name: "has_cargo_center"},
+				&core.BoolField{Name: "has_clinic"},
+				&core.BoolField{Name: "has_refuel"},
+				&core.BoolField{Name: "has_repair"},
+				&core.BoolField{Name: "has_loading_dock"},
+				&core.BoolField{Name: "has_docking_port"},
+				&core.BoolField{Name: "has_freight_elevator"},
+				&core.BoolField{Name: "archived"},
+				&core.DateField{Name: "archived_at"},
+				&core.AutodateField{Name: "created", OnCreate: true},
+				&core.AutodateField{Name: "updated", OnCreate: true, OnUpdate: true},
+			)
+			locations.AddIndex("idx_"+name+"_uex_id", true, "uex_id", "uex_id != 0")
+			locations.AddIndex("idx_"+name+"_star_system", false, "star_system", "")
+			locations.AddIndex("idx_"+name+"_archived", false, "archived", "")
+			locations, err := ensureCollection(app, locations)
+			if err != nil {
+				return err
+			}
+			locationIds[name] = locations.Id
+		}
+
+		terminals, err := app.FindCollectionByNameOrId("terminals")
+		if err != nil {
+			return err
+		}
+		terminals.Fields.Add(
+			&core.RelationField{Name: "city", CollectionId: locationIds["cities"], MaxSelect: 1},
+			&core.RelationField{Name: "surface_outpost", CollectionId: locationIds["surface_outposts"], MaxSelect: 1},
+		)
+		return app.Save(terminals)
+	}, func(app core.App) error {
+		terminals, err := app.FindCollectionByNameOrId("terminals")
+		if err != nil {
+			return err
+		}
+		terminals.Fields.RemoveByName("city")
+		terminals.Fields.RemoveByName("surface_outpost")
+		if err := app.Save(terminals); err != nil {
+			return err
+		}
+
+		return deleteCollections(app, "surface_outposts", "cities")
+	})
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+	"github.com/pocketbase/pocketbase/tools/types"
+)
+
+// Links the outposts of the organizations to where they sit: a star system,
+// planet and moon, optionally with a region and surface coordinates.
+// The consistency of the three relations is enforced by hooks.ValidateOutpostLocation.
+func init() {
+	m.Register(func(app core.App) error {
+		collectionIds := map[string]string{}
+		for _, name := range []string{"star_systems", "planets", "moons"} {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+			collectionIds[name] = collection.Id
+		}
+
+		outposts, err := app.FindCollectionByNameOrId("outposts")
+		if err != nil {
+			return err
+		}
+
+		outposts.Fields.Add(
+			&core.RelationField{Name: "star_system", CollectionId: collectionIds["star_systems"], MaxSelect: 1},
+			&core.RelationField{Name: "planet", CollectionId: collectionIds["planets"], MaxSelect: 1},
+			&core.RelationField{Name: "moon", CollectionId: collectionIds["moons"], MaxSelect: 1},
+			&core.TextField{Name: "region", Max: 100},
+			&core.NumberField{Name: "latitude", Min: types.Pointer(-90.0), Max: types.Pointer(90.0)},
+			&core.NumberField{Name: "longitude", Min: types.Pointer(-180.0), Max: types.Pointer(180.0)},
+		)
+		outposts.AddIndex("idx_outposts_star_system", false, "star_system", "")
+		outposts.AddIndex("idx_outposts_planet", false, "planet", "")
+		outposts.AddIndex("idx_outposts_moon", false, "moon", "")
+
+		return app.Save(outposts)
+	}, func(app core.App) error {
+		outposts, err := app.FindCollectionByNameOrId("outposts")
+		if err != nil {
+			return err
+		}
+
+		outposts.RemoveIndex("idx_outposts_star_system")
+		outposts.RemoveIndex("idx_outposts_planet")
+		outposts.RemoveIndex("idx_outposts_moon")
+		for _, name := range []string{"star_system", "planet", "moon", "region", "latitude", "longitude"} {
+			outposts.Fields.RemoveByName(name)
+		}
+
+		return app.Save(outposts)
+	})
+}
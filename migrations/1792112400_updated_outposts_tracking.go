@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Lets every outpost choose the commodities it tracks: all of them (the default),
+// the commodities of some types (e.g. Ore, Raw) or an explicit list.
+// Only the outposts tracking all commodities get their outpost_commodities rows upfront, when
+// they are created or switched to tracking all commodities and for every new commodity
+// (see hooks.BackfillOutpost and hooks.BackfillOutpostCommodities), members create the rows
+// of the other outposts on demand when the stock of a tracked commodity first changes.
+func init() {
+	m.Register(func(app core.App) error {
+		commodities, err := app.FindCollectionByNameOrId("commodities")
+		if err != nil {
+			return err
+		}
+
+		outposts, err := app.FindCollectionByNameOrId("outposts")
+		if err != nil {
+			return err
+		}
+		outposts.Fields.Add(
+			&core.SelectField{Name: "tracking", Values: []string{"all", "types", "list"}, MaxSelect: 1},
+			&core.JSONField{Name: "tracked_types", MaxSize: 2000},
+			&core.RelationField{Name: "tracked_commodities", CollectionId: commodities.Id, MaxSelect: 1000},
+		)
+		if err := app.Save(outposts); err != nil {
+			return err
+		}
+
+		outpostCommodities, err := app.FindCollectionByNameOrId("outpost_commodities")
+		if err != nil {
+			return err
+		}
+		outpostCommodities.CreateRule = organizationMemberRule
+		return app.Save(outpostCommodities)
+	}, func(app core.App) error {
+		outpostCommodities, err := app.FindCollectionByNameOrId("outpost_commodities")
+		if err != nil {
+			return err
+		}
+		outpostCommodities.CreateRule = nil
+		if err := app.Save(outpostCommodities); err != nil {
+			return err
+		}
+
+		outposts, err := app.FindCollectionByNameOrId("outposts")
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"tracking", "tracked_types", "tracked_commodities"} {
+			outposts.Fields.RemoveByName(name)
+		}
+		return app.Save(outposts)
+	})
+}
@@ -0,0 +1,80 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// inventoryRelations are the relations of the inventory collections to the records
+// whose deletion archives them, see hooks.ArchiveInventory.
+var inventoryRelations = map[string][]string{
+	"outpost_commodities":       {"outpost", "commodity"},
+	"outpost_commodity_changes": {"outpost", "commodity"},
+}
+
+// Lets the inventory and its ledger outlive the deletion of their outpost or commodity:
+// the rows are archived with a snapshot of the names instead, and the relations are
+// no longer required so PocketBase unsets them instead of refusing the deletion.
+// Only one active row is allowed per (outpost, commodity).
+func init() {
+	m.Register(func(app core.App) error {
+		for name, relations := range inventoryRelations {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			for _, relation := range relations {
+				if field, ok := collection.Fields.GetByName(relation).(*core.RelationField); ok {
+					field.Required = false
+				}
+			}
+			collection.Fields.Add(
+				&core.TextField{Name: "outpost_name", Max: 100},
+				&core.TextField{Name: "commodity_name", Max: 100},
+				&core.BoolField{Name: "archived"},
+				&core.DateField{Name: "archived_at"},
+			)
+			collection.AddIndex("idx_"+name+"_archived", false, "archived", "")
+
+			if name == "outpost_commodities" {
+				collection.RemoveIndex("idx_outpost_commodities_outpost_commodity")
+				collection.AddIndex("idx_outpost_commodities_outpost_commodity", true, "outpost, commodity", "archived = false")
+			}
+
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}, func(app core.App) error {
+		for name, relations := range inventoryRelations {
+			collection, err := app.FindCollectionByNameOrId(name)
+			if err != nil {
+				return err
+			}
+
+			for _, relation := range relations {
+				if field, ok := collection.Fields.GetByName(relation).(*core.RelationField); ok {
+					field.Required = true
+				}
+			}
+			for _, field := range []string{"outpost_name", "commodity_name", "archived", "archived_at"} {
+				collection.Fields.RemoveByName(field)
+			}
+			collection.RemoveIndex("idx_" + name + "_archived")
+
+			if name == "outpost_commodities" {
+				collection.RemoveIndex("idx_outpost_commodities_outpost_commodity")
+				collection.AddIndex("idx_outpost_commodities_outpost_commodity", true, "outpost, commodity", "")
+			}
+
+			if err := app.Save(collection); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// changeReasons are the reasons a stock can change for, see hooks.ChangeReasons.
+var changeReasons = []string{"mined", "refined", "bought", "sold", "transferred", "adjusted", "lost"}
+
+// Records who changed a stock, why, and what the change relates to
+// (e.g. the outpost of a transfer), next to the amount of the change.
+func init() {
+	m.Register(func(app core.App) error {
+		users, err := app.FindCollectionByNameOrId("users")
+		if err != nil {
+			return err
+		}
+
+		changes, err := app.FindCollectionByNameOrId("outpost_commodity_changes")
+		if err != nil {
+			return err
+		}
+
+		changes.Fields.Add(
+			&core.RelationField{Name: "actor", CollectionId: users.Id, MaxSelect: 1},
+			&core.SelectField{Name: "reason", Values: changeReasons, MaxSelect: 1},
+			&core.TextField{Name: "note", Max: 500},
+			&core.TextField{Name: "reference_collection", Max: 50},
+			&core.TextField{Name: "reference_id", Max: 15},
+		)
+		changes.AddIndex("idx_outpost_commodity_changes_reason", false, "reason", "")
+
+		return app.Save(changes)
+	}, func(app core.App) error {
+		changes, err := app.FindCollectionByNameOrId("outpost_commodity_changes")
+		if err != nil {
+			return err
+		}
+
+		changes.RemoveIndex("idx_outpost_commodity_changes_reason")
+		for _, name := range []string{"actor", "reason", "note", "reference_collection", "reference_id"} {
+			changes.Fields.RemoveByName(name)
+		}
+
+		return app.Save(changes)
+	})
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Makes the inventory ledger-first: members can no longer create or update outpost_commodities
+// records (and so write their amount) through the records API. Stock changes go through the
+// inventory adjust endpoint, which writes the ledger entry and the amount in one transaction.
+// Superusers bypass the rules, hooks.BlockDirectAmountWrites rejects their amount writes.
+func init() {
+	m.Register(func(app core.App) error {
+		outpostCommodities, err := app.FindCollectionByNameOrId("outpost_commodities")
+		if err != nil {
+			return err
+		}
+
+		outpostCommodities.CreateRule = nil
+		outpostCommodities.UpdateRule = nil
+
+		return app.Save(outpostCommodities)
+	}, func(app core.App) error {
+		outpostCommodities, err := app.FindCollectionByNameOrId("outpost_commodities")
+		if err != nil {
+			return err
+		}
+
+		outpostCommodities.CreateRule = organizationMemberRule
+		outpostCommodities.UpdateRule = organizationMemberRule
+
+		return app.Save(outpostCommodities)
+	})
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Adds the diff of dry runs to sync_runs, so it can still be read once the run finished.
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		// The diff of a full star systems dry run can exceed the default 5MB
+		collection.Fields.Add(&core.JSONField{Name: "diff", MaxSize: 20 << 20})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("sync_runs")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("diff")
+
+		return app.Save(collection)
+	})
+}
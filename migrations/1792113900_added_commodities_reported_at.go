@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/pocketbase/pocketbase/core"
+	m "github.com/pocketbase/pocketbase/migrations"
+)
+
+// Adds reported_at to commodities: when UEX last updated the commodity and its prices.
+// The price history uses it as the observation time of the prices.
+func init() {
+	m.Register(func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("commodities")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.Add(&core.DateField{Name: "reported_at"})
+
+		return app.Save(collection)
+	}, func(app core.App) error {
+		collection, err := app.FindCollectionByNameOrId("commodities")
+		if err != nil {
+			return err
+		}
+
+		collection.Fields.RemoveByName("reported_at")
+
+		return app.Save(collection)
+	})
+}
@@ -0,0 +1,122 @@
+// Package migrations holds the versioned PulsePoint schema.
+//
+// Every schema change ships as a new "<unix timestamp>_<description>.go" file
+// registering its up and down functions with m.Register. They are applied on
+// "serve" or with "./pulsepoint migrate up", see the migratecmd registration in main.
+package migrations
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/pocketbase/dbx"
+	"github.com/pocketbase/pocketbase/core"
+	"github.com/pocketbase/pocketbase/tools/dbutils"
+)
+
+// ensureCollection saves the given collection unless one with the same name already exists,
+// and returns the stored collection so its id can be used by relation fields.
+//
+// An existing collection (from a database created before the schema was versioned) is
+// completed with the fields and indexes of the given collection it doesn't have yet,
+// matched by name. Its other fields, indexes and rules are left untouched, and its
+// records get the zero value of the added fields.
+func ensureCollection(app core.App, collection *core.Collection) (*core.Collection, error) {
+	existing, err := app.FindCollectionByNameOrId(collection.Name)
+	if err != nil {
+		if err := app.Save(collection); err != nil {
+			return nil, err
+		}
+		return collection, nil
+	}
+
+	var added []string
+
+	for _, field := range collection.Fields {
+		if existing.Fields.GetByName(field.GetName()) == nil {
+			existing.Fields.Add(field)
+			added = append(added, field.GetName())
+		}
+	}
+
+	indexes := map[string]bool{}
+	for _, index := range existing.Indexes {
+		indexes[dbutils.ParseIndex(index).IndexName] = true
+	}
+	for _, index := range collection.Indexes {
+		if name := dbutils.ParseIndex(index).IndexName; !indexes[name] {
+			existing.Indexes = append(existing.Indexes, index)
+			added = append(added, name)
+		}
+	}
+
+	if len(added) == 0 {
+		return existing, nil
+	}
+
+	app.Logger().Info("Completing existing collection", "collection", existing.Name, "added", added)
+	if err := app.Save(existing); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// deleteCollections deletes the named collections in the given order, skipping the missing ones.
+func deleteCollections(app core.App, names ...string) error {
+	for _, name := range names {
+		collection, err := app.FindCollectionByNameOrId(name)
+		if err != nil {
+			continue
+		}
+
+		if err := app.Delete(collection); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveCreatedCollections remembers, in the _params table, the names of the collections
+// created by a migration, so its down function only deletes those.
+func saveCreatedCollections(app core.App, key string, names []string) error {
+	value, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+
+	_, err = app.DB().NewQuery("INSERT OR REPLACE INTO {{_params}} ([[id]], [[value]]) VALUES ({:id}, {:value})").
+		Bind(dbx.Params{"id": key, "value": string(value)}).
+		Execute()
+	return err
+}
+
+// findCreatedCollections returns the names saved by saveCreatedCollections, or none when nothing was saved.
+func findCreatedCollections(app core.App, key string) ([]string, error) {
+	var value string
+	err := app.DB().NewQuery("SELECT [[value]] FROM {{_params}} WHERE [[id]] = {:id} LIMIT 1").
+		Bind(dbx.Params{"id": key}).
+		Row(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if err := json.Unmarshal([]byte(value), &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// forgetCreatedCollections removes the names saved by saveCreatedCollections.
+func forgetCreatedCollections(app core.App, key string) error {
+	_, err := app.DB().NewQuery("DELETE FROM {{_params}} WHERE [[id]] = {:id}").
+		Bind(dbx.Params{"id": key}).
+		Execute()
+	return err
+}